@@ -0,0 +1,303 @@
+// Command deepcopy generates deep copy functions for the types in a package.
+//
+// It is intended to be used with `go generate`, e.g.:
+//
+//	//go:generate deepcopy -type Foo,Bar -output zz_deepcopy.go
+//
+// When -type is not set, copy functions are generated for every type in the
+// package which is annotated with a `// +deepcopy` comment.
+//
+// The package is loaded from source, so there is no need to write (or compile)
+// a program which imports the package being generated for. The output file is
+// left out when loading it, so that it is generated the same way from one run
+// to the next, and the generated code is type checked along with the rest of
+// the package before it is written.
+//
+// With -tests a test for each copy method is generated as well, in a file next
+// to the output file with a _test.go suffix. The helpers the tests use are
+// named after the output file, so several outputs in the same package can
+// have tests.
+//
+// With -verify the output file is not written. Instead, the command fails with
+// a diff of the changes if it is out of date, e.g. in CI.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/cpuguy83/go-generate/deepcopy"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of type names; defaults to the types marked with // +deepcopy")
+	output    = flag.String("output", "zz_deepcopy.go", "output file name, relative to the package directory")
+	ref       = flag.String("ref", "o", "name of the receiver used in the generated functions")
+	method    = flag.String("method", "Copy", "name of the generated method")
+	pointer   = flag.Bool("pointer-receiver", false, "generate the method on a pointer to the type")
+	k8s       = flag.Bool("kubernetes", false, "generate DeepCopyInto and DeepCopy methods instead of Copy")
+	object    = flag.String("object", "", "interface returned by a generated DeepCopyObject method, qualified by import path (e.g. k8s.io/apimachinery/pkg/runtime.Object); implies -kubernetes")
+	ignore    = flag.String("ignore", "", "comma-separated list of types, qualified by import path (e.g. time.Time), to ignore errors from")
+	copiers   = flag.String("copier", "", "comma-separated list of type=func pairs, qualified by import path (e.g. time.Time=example.com/timeutil.Clone), of functions to copy every value of the type with")
+	best      = flag.Bool("best-effort", false, "generate code for every field which can be copied, skipping the rest")
+	aliasing  = flag.Bool("preserve-aliasing", false, "keep shared references shared, and cycles intact, in the copy")
+	fallback  = flag.String("interface-fallback", "share", "how to copy interface values of unknown types: share, nil, panic or error (which fails for any interface without a Copy method)")
+	chans     = flag.String("chan-policy", "error", "how to copy channels: error, share, nil or fresh")
+	funcs     = flag.String("func-policy", "share", "how to copy funcs: error, share or nil")
+	tags      = flag.String("tags", "", "build constraint for the generated file, e.g. 'linux && !race'")
+	verifyOut = flag.Bool("verify", false, "check that the output file is up to date instead of writing it, printing a diff if it is not")
+	tests     = flag.Bool("tests", false, "also generate a test for each copy method, in the output file name with a _test.go suffix")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of deepcopy:\n")
+	fmt.Fprintf(os.Stderr, "\tdeepcopy [flags] -type T [directory]\n")
+	fmt.Fprintf(os.Stderr, "\tdeepcopy [flags] [directory] # types marked with // +deepcopy\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("deepcopy: ")
+	flag.Usage = usage
+	flag.Parse()
+
+	dir := "."
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		dir = flag.Arg(0)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := []deepcopy.Option{
+		deepcopy.Receiver(*ref),
+		deepcopy.MethodName(*method),
+		deepcopy.IgnoreTypeNames(splitList(*ignore)...),
+	}
+	if *pointer {
+		opts = append(opts, deepcopy.PointerReceiver())
+	}
+	if *k8s {
+		opts = append(opts, deepcopy.KubernetesStyle())
+	}
+	if *object != "" {
+		var importPath string
+		name := *object
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			importPath, name = name[:i], name[i+1:]
+		}
+		opts = append(opts, deepcopy.DeepCopyObject(name, importPath))
+	}
+	if err := registerCopiers(splitList(*copiers)); err != nil {
+		log.Fatal(err)
+	}
+	if *tags != "" {
+		opts = append(opts, deepcopy.BuildTags(*tags))
+	}
+	if *best {
+		opts = append(opts, deepcopy.BestEffort())
+	}
+	if *aliasing {
+		opts = append(opts, deepcopy.PreserveAliasing())
+	}
+	for _, p := range []struct {
+		value  string
+		option func(deepcopy.Policy) deepcopy.Option
+	}{
+		{*fallback, deepcopy.InterfaceFallback},
+		{*chans, deepcopy.ChanPolicy},
+		{*funcs, deepcopy.FuncPolicy},
+	} {
+		policy, err := deepcopy.ParsePolicy(p.value)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, p.option(policy))
+	}
+
+	outputName := *output
+	if !filepath.IsAbs(outputName) {
+		outputName = filepath.Join(dir, outputName)
+	}
+
+	files := []bool{false}
+	if *tests {
+		files = append(files, true)
+	}
+	for _, isTest := range files {
+		name := outputName
+		if isTest {
+			name = testFileName(outputName)
+		}
+
+		if *verifyOut {
+			err := verify(dir, outputName, isTest, splitList(*typeNames), opts...)
+			var stale *deepcopy.StaleError
+			if errors.As(err, &stale) {
+				fmt.Print(stale.Diff)
+				log.Fatalf("%s is out of date", name)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
+
+		f, err := generateFile(dir, outputName, isTest, splitList(*typeNames), opts...)
+		if err != nil {
+			if f == nil {
+				log.Fatal(err)
+			}
+			if !isTest {
+				log.Printf("skipped fields which cannot be copied: %v", err)
+			}
+		}
+		src, err := f.Bytes()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, src, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// testFileName returns the name of the file the tests for the code in the
+// file `output` are written to.
+func testFileName(output string) string {
+	return strings.TrimSuffix(output, ".go") + "_test.go"
+}
+
+// helperPrefix returns the prefix of the names of the helpers in the test file
+// for the file `output`, so that the test files for different outputs in the
+// same package don't declare the same helpers: the name of the file in lower
+// camel case, e.g. `zzDeepcopy` for zz_deepcopy.go.
+func helperPrefix(output string) string {
+	name := strings.TrimSuffix(filepath.Base(output), ".go")
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	prefix := ""
+	for i, w := range words {
+		r := []rune(w)
+		if i == 0 {
+			r[0] = unicode.ToLower(r[0])
+		} else {
+			r[0] = unicode.ToUpper(r[0])
+		}
+		prefix += string(r)
+	}
+	if prefix == "" || unicode.IsDigit([]rune(prefix)[0]) {
+		prefix = "deepcopy" + prefix
+	}
+	return prefix
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// registerCopiers registers the copiers given as `type=func` pairs with the
+// deepcopy package.
+func registerCopiers(pairs []string) error {
+	for _, pair := range pairs {
+		i := strings.Index(pair, "=")
+		if i <= 0 || i == len(pair)-1 {
+			return fmt.Errorf("invalid copier %q, expected type=func", pair)
+		}
+		typeName, funcName := pair[:i], pair[i+1:]
+		var importPath string
+		if j := strings.LastIndex(funcName, "."); j >= 0 {
+			importPath, funcName = funcName[:j], funcName[j+1:]
+		}
+		deepcopy.RegisterCopierName(typeName, funcName, importPath)
+	}
+	return nil
+}
+
+// loadPackage loads the package in `dir`, leaving out the file `output` if it
+// is in the package, and checks that there are types to generate for.
+func loadPackage(dir, output string, typeNames []string) (*deepcopy.Package, error) {
+	var exclude []string
+	if filepath.Clean(filepath.Dir(output)) == filepath.Clean(dir) {
+		exclude = append(exclude, filepath.Base(output))
+	}
+	pkg, err := deepcopy.LoadPackage(dir, exclude...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(typeNames) == 0 && len(pkg.MarkedTypes()) == 0 {
+		return nil, fmt.Errorf("no types given with -type or marked with // +deepcopy in %s", pkg.Path())
+	}
+	return pkg, nil
+}
+
+// generateFile generates the file which holds the copy functions for each of
+// the passed in types from the package in `dir`, to be written to `output`, or
+// the tests for them if `isTest` is set.
+// The copy functions are type checked along with the rest of the package, so
+// that code which does not compile is never written.
+// With the `deepcopy.BestEffort` option the file is returned along with the
+// errors for any fields which were skipped.
+func generateFile(dir, output string, isTest bool, typeNames []string, opts ...deepcopy.Option) (*deepcopy.File, error) {
+	pkg, err := loadPackage(dir, output, typeNames)
+	if err != nil {
+		return nil, err
+	}
+	if isTest {
+		opts = append(opts[:len(opts):len(opts)], deepcopy.TestHelperPrefix(helperPrefix(output)))
+		return pkg.GenerateTestFile(typeNames, opts...)
+	}
+	f, err := pkg.GenerateFile(typeNames, opts...)
+	if f == nil {
+		return nil, err
+	}
+	// the generated code must compile along with the rest of the package
+	src, ferr := f.Bytes()
+	if ferr != nil {
+		return nil, ferr
+	}
+	if cerr := pkg.Check(filepath.Base(output), src); cerr != nil {
+		return nil, fmt.Errorf("the generated code does not compile: %v", cerr)
+	}
+	return f, err
+}
+
+// verify checks that the file `output`, or its test file if `isTest` is set,
+// holds the code generated for the passed in types from the package in `dir`,
+// returning a `*deepcopy.StaleError` if it does not. A missing file is out of
+// date.
+func verify(dir, output string, isTest bool, typeNames []string, opts ...deepcopy.Option) error {
+	name := output
+	if isTest {
+		name = testFileName(output)
+	}
+	existing, err := ioutil.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := generateFile(dir, output, isTest, typeNames, opts...)
+	if f == nil {
+		return err
+	}
+	return f.Verify(existing)
+}
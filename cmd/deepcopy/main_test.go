@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cpuguy83/go-generate/deepcopy"
+)
+
+const fixturesDir = "../../deepcopy/fixtures"
+
+func TestGenerate(t *testing.T) {
+	src, err := generate(fixturesDir, filepath.Join(fixturesDir, "zz_deepcopy.go"), []string{"Foo", "StrSlice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := []byte(`// Code generated by deepcopy. DO NOT EDIT.
+
+package fixtures
+
+func (o Foo) Copy() Foo {
+	oCopy := o
+	if o.B != nil {
+		oCopy.B = make(map[string]string, len(o.B))
+		for i0, v0 := range o.B {
+			oCopy.B[i0] = v0
+		}
+
+	}
+
+	return oCopy
+}
+
+func (o StrSlice) Copy() StrSlice {
+	oCopy := make(StrSlice, len(o))
+	for i0, v0 := range o {
+		oCopy[i0] = v0
+	}
+
+	return oCopy
+}
+`)
+	if !bytes.Equal(src, x) {
+		t.Fatalf("expected:\n%s\n\ngot:\n%s", x, src)
+	}
+}
+
+// generate returns the source of the file generated by `generateFile`, which
+// holds the copy functions for each of the passed in types from the package in
+// `dir`, to be written to `output`.
+// With the `deepcopy.BestEffort` option the file is returned along with the
+// errors for any fields which were skipped.
+func generate(dir, output string, typeNames []string, opts ...deepcopy.Option) ([]byte, error) {
+	f, err := generateFile(dir, output, false, typeNames, opts...)
+	if f == nil {
+		return nil, err
+	}
+	src, ferr := f.Bytes()
+	if ferr != nil {
+		return nil, ferr
+	}
+	return src, err
+}
+
+// writePackage writes a package with a single file containing `src` to a
+// temporary directory.
+func writePackage(t *testing.T, src string) string {
+	dir, err := ioutil.TempDir("", "deepcopy-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "src.go"), []byte(src), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestGenerateMarkedTypes(t *testing.T) {
+	dir := writePackage(t, `package marked
+
+// +deepcopy
+type A struct {
+	// +deepcopy:skip
+	M map[string]string
+}
+
+type B []string
+`)
+	defer os.RemoveAll(dir)
+
+	src, err := generate(dir, filepath.Join(dir, "zz_deepcopy.go"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := []byte(`// Code generated by deepcopy. DO NOT EDIT.
+
+package marked
+
+func (o A) Copy() A {
+	oCopy := o
+
+	return oCopy
+}
+`)
+	if !bytes.Equal(src, x) {
+		t.Fatalf("expected:\n%s\n\ngot:\n%s", x, src)
+	}
+
+	dir2 := writePackage(t, "package unmarked\n\ntype A struct{}\n")
+	defer os.RemoveAll(dir2)
+	if _, err := generate(dir2, filepath.Join(dir2, "zz_deepcopy.go"), nil); err == nil {
+		t.Fatal("expected error when no types are marked")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	dir := writePackage(t, `package worker
+
+type Worker struct {
+	done chan struct{}
+}
+`)
+	defer os.RemoveAll(dir)
+
+	_, err := generate(dir, filepath.Join(dir, "zz_deepcopy.go"), []string{"Worker"})
+	if !errors.Is(err, deepcopy.ErrUnsupportedType) || !strings.HasPrefix(err.Error(), "Worker.done: ") {
+		t.Fatalf("expected unsupported type error, got: %v", err)
+	}
+
+	if _, err := generate(dir, filepath.Join(dir, "zz_deepcopy.go"), []string{"Worker"}, deepcopy.ChanPolicy(deepcopy.PolicyShare)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterCopiers(t *testing.T) {
+	dir := writePackage(t, `package handles
+
+import "github.com/cpuguy83/go-generate/deepcopy/fixtures"
+
+type Handles struct {
+	H []fixtures.Handle
+}
+`)
+	defer os.RemoveAll(dir)
+
+	if err := registerCopiers([]string{"fixtures.Handle"}); err == nil {
+		t.Fatal("expected error for copier without a func")
+	}
+	if err := registerCopiers([]string{"github.com/cpuguy83/go-generate/deepcopy/fixtures.Handle=github.com/cpuguy83/go-generate/deepcopy/fixtures.CopyHandle"}); err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(dir, filepath.Join(dir, "zz_deepcopy.go"), []string{"Handles"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(src, []byte(".CopyHandle(v0)")) {
+		t.Fatalf("expected copier to be used:\n%s", src)
+	}
+}
+
+func TestGenerateAllErrors(t *testing.T) {
+	dir := writePackage(t, `package worker
+
+type A struct {
+	done chan struct{}
+}
+
+type B struct {
+	done  chan struct{}
+	Names []string
+}
+`)
+	defer os.RemoveAll(dir)
+
+	_, err := generate(dir, filepath.Join(dir, "zz_deepcopy.go"), []string{"A", "B"})
+	errs, ok := err.(deepcopy.Errors)
+	if !ok || len(errs) != 2 || errs[0].Path != "A.done" || errs[1].Path != "B.done" {
+		t.Fatalf("expected errors for A.done and B.done, got: %v", err)
+	}
+
+	src, err := generate(dir, filepath.Join(dir, "zz_deepcopy.go"), []string{"A", "B"}, deepcopy.BestEffort())
+	if _, ok := err.(deepcopy.Errors); !ok {
+		t.Fatalf("expected errors for skipped fields, got: %v", err)
+	}
+	if !bytes.Contains(src, []byte("// deepcopy: skipped B.done: ")) || !bytes.Contains(src, []byte("oCopy.Names = make([]string, len(o.Names))")) {
+		t.Fatalf("unexpected output:\n%s", src)
+	}
+}
+
+func TestGenerateTwice(t *testing.T) {
+	dir := writePackage(t, `package twice
+
+type Node struct {
+	Next   *Node
+	Labels map[string]string
+}
+`)
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "zz_deepcopy.go")
+
+	src, err := generate(dir, output, []string{"Node"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(output, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the Copy method on Node in the output file is not used
+	src2, err := generate(dir, output, []string{"Node"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, src2) {
+		t.Fatalf("expected:\n%s\n\ngot:\n%s", src, src2)
+	}
+
+	// nor does a broken output file get in the way
+	if err := ioutil.WriteFile(output, []byte("package twice\n\nfunc (o Node) Copy() Node {\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src2, err = generate(dir, output, []string{"Node"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, src2) {
+		t.Fatalf("expected:\n%s\n\ngot:\n%s", src, src2)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir := writePackage(t, `package verify
+
+// +deepcopy
+type A struct {
+	Names []string
+}
+`)
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "zz_deepcopy.go")
+
+	var stale *deepcopy.StaleError
+	if err := verify(dir, output, false, nil); !errors.As(err, &stale) {
+		t.Fatalf("expected a missing file to be out of date, got: %v", err)
+	}
+
+	src, err := generate(dir, output, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(output, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(dir, output, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "src.go"), []byte(`package verify
+
+// +deepcopy
+type A struct {
+	Names []string
+	Tags  map[string]string
+}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = verify(dir, output, false, nil)
+	if !errors.As(err, &stale) || !strings.Contains(stale.Diff, "+\tif o.Tags != nil {\n") {
+		t.Fatalf("expected a diff adding Tags, got: %v", err)
+	}
+	if existing, _ := ioutil.ReadFile(output); !bytes.Equal(existing, src) {
+		t.Fatal("the output file was written")
+	}
+}
+
+func TestGenerateTests(t *testing.T) {
+	dir := writePackage(t, `package tested
+
+// +deepcopy
+type A struct {
+	Names []string
+}
+`)
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "zz_deepcopy.go")
+
+	f, err := generateFile(dir, output, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(src, []byte("func TestCopy_A(t *testing.T) {")) {
+		t.Fatalf("unexpected output:\n%s", src)
+	}
+	if err := ioutil.WriteFile(testFileName(output), src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(dir, output, true, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateTestsForTwoOutputs(t *testing.T) {
+	dir := writePackage(t, `package tested
+
+type A struct {
+	Names []string
+}
+
+type B struct {
+	Tags map[string]string
+}
+`)
+	defer os.RemoveAll(dir)
+
+	// the two test files are compiled together with the package
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for typeName, output := range map[string]string{"A": "zz_a.go", "B": "zz_b.go"} {
+		output = filepath.Join(dir, output)
+		for _, isTest := range []bool{false, true} {
+			f, err := generateFile(dir, output, isTest, []string{typeName})
+			if err != nil {
+				t.Fatal(err)
+			}
+			src, err := f.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := output
+			if isTest {
+				name = testFileName(output)
+			}
+			file, err := parser.ParseFile(fset, name, src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, file)
+		}
+	}
+	file, err := parser.ParseFile(fset, filepath.Join(dir, "src.go"), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, file)
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("tested", fset, files, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHelperPrefix(t *testing.T) {
+	for output, expected := range map[string]string{
+		"zz_deepcopy.go":            "zzDeepcopy",
+		"/a/b/zz_generated.copy.go": "zzGeneratedCopy",
+		"copy-funcs.go":             "copyFuncs",
+		"1_copy.go":                 "deepcopy1Copy",
+	} {
+		if actual := helperPrefix(output); actual != expected {
+			t.Errorf("%s: expected %s, got: %s", output, expected, actual)
+		}
+	}
+}
+
+func TestGenerateCallsGeneratedMethod(t *testing.T) {
+	// the package uses the method which is generated, which does not exist
+	// when the output file is left out
+	dir := writePackage(t, `package calls
+
+type Node struct {
+	Labels map[string]string
+}
+
+func cloneNode(n Node) Node {
+	return n.Copy()
+}
+`)
+	defer os.RemoveAll(dir)
+
+	if _, err := generate(dir, filepath.Join(dir, "zz_deepcopy.go"), []string{"Node"}); err != nil {
+		t.Fatal(err)
+	}
+	// only the methods which are generated
+	if _, err := generate(dir, filepath.Join(dir, "zz_deepcopy.go"), []string{"Node"}, deepcopy.KubernetesStyle()); err == nil {
+		t.Fatal("expected an error for the Copy method, which is not generated in Kubernetes style")
+	}
+	kdir := writePackage(t, "package calls\n\ntype Node struct{}\n\nfunc cloneNode(n *Node) *Node {\n\treturn n.DeepCopy()\n}\n")
+	defer os.RemoveAll(kdir)
+	if _, err := generate(kdir, filepath.Join(kdir, "zz_deepcopy.go"), []string{"Node"}, deepcopy.KubernetesStyle()); err != nil {
+		t.Fatal(err)
+	}
+	for _, src := range []string{
+		// a method which is not generated
+		"func cloneNode(n *Node) *Node {\n\treturn n.Clone()\n}\n",
+		// a type which is not generated for
+		"type Other struct{}\n\nfunc cloneOther(o Other) Other {\n\treturn o.Copy()\n}\n",
+		"type Other struct{}\n\ntype Copier interface{ Copy() Other }\n\nvar _ Copier = Other{}\n",
+		// a method which is not generated, missing from an interface
+		"type Cloner interface{ Clone() Node }\n\nvar _ Cloner = Node{}\n",
+	} {
+		dir := writePackage(t, "package calls\n\ntype Node struct{}\n\n"+src)
+		defer os.RemoveAll(dir)
+		if _, err := generate(dir, filepath.Join(dir, "zz_deepcopy.go"), []string{"Node"}); err == nil {
+			t.Fatalf("expected an error for:\n%s", src)
+		}
+	}
+}
+
+func TestGenerateImplementsInterface(t *testing.T) {
+	// the package asserts that the types implement an interface with the
+	// generated methods, which do not exist when the output file is left out
+	for _, c := range []struct {
+		src  string
+		opts []deepcopy.Option
+	}{
+		{"type Copier interface{ Copy() Node }\n\nvar _ Copier = Node{}\n", nil},
+		{"type Object interface{ DeepCopyObject() Object }\n\nvar _ Object = &Node{}\n", []deepcopy.Option{deepcopy.DeepCopyObject("Object", "")}},
+	} {
+		dir := writePackage(t, "package implements\n\ntype Node struct {\n\tLabels map[string]string\n}\n\n"+c.src)
+		defer os.RemoveAll(dir)
+		output := filepath.Join(dir, "zz_deepcopy.go")
+
+		src, err := generate(dir, output, []string{"Node"}, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(output, src, 0644); err != nil {
+			t.Fatal(err)
+		}
+		// and the output can be generated again
+		if err := verify(dir, output, false, []string{"Node"}, c.opts...); err != nil {
+			t.Fatal(err)
+		}
+		src2, err := generate(dir, output, []string{"Node"}, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(src, src2) {
+			t.Fatalf("expected:\n%s\n\ngot:\n%s", src, src2)
+		}
+	}
+}
+
+func TestGenerateChecksOutput(t *testing.T) {
+	dir := writePackage(t, `package checked
+
+type Object struct {
+	Meta map[string]struct{ A *string }
+}
+
+// Clone has a field with the name of the generated method
+type Clone struct {
+	Copy map[string]string
+}
+`)
+	defer os.RemoveAll(dir)
+
+	if _, err := generate(dir, filepath.Join(dir, "zz_deepcopy.go"), []string{"Object"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := generate(dir, filepath.Join(dir, "zz_deepcopy.go"), []string{"Clone"})
+	if err == nil || !strings.Contains(err.Error(), "does not compile") {
+		t.Fatalf("expected an error for code which does not compile, got: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package deepcopy
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// builtinKind is a strategy for copying a type from the standard library.
+type builtinKind int
+
+const (
+	// builtinShare assigns the value, for types which are immutable or safe
+	// to share between the original and the copy.
+	builtinShare builtinKind = iota
+	// builtinClone copies a named byte slice with `bytes.Clone`.
+	builtinClone
+	// builtinSet copies a pointer with a method which sets the receiver to
+	// its argument, e.g. `new(big.Int).Set(x)`.
+	builtinSet
+	// builtinDeref copies a pointer to a struct which can be copied by
+	// assignment.
+	builtinDeref
+)
+
+// builtin is the built in way of copying a type from the standard library.
+type builtin struct {
+	kind builtinKind
+	// method is the name of the method used by builtinSet
+	method string
+}
+
+// builtins are the types from the standard library which are copied without
+// walking the type, by type key (see `typeKey`).
+// Most of them have unexported fields which could not be copied otherwise.
+var builtins = map[string]builtin{
+	"time.Time":                {kind: builtinShare},
+	"*time.Location":           {kind: builtinShare},
+	"*regexp.Regexp":           {kind: builtinShare},
+	"*math/big.Int":            {kind: builtinSet, method: "Set"},
+	"*math/big.Float":          {kind: builtinSet, method: "Copy"},
+	"*math/big.Rat":            {kind: builtinSet, method: "Set"},
+	"net/url.URL":              {kind: builtinShare},
+	"*net/url.URL":             {kind: builtinDeref},
+	"*net/url.Userinfo":        {kind: builtinShare},
+	"net.IP":                   {kind: builtinClone},
+	"net.IPMask":               {kind: builtinClone},
+	"net.HardwareAddr":         {kind: builtinClone},
+	"encoding/json.RawMessage": {kind: builtinClone},
+	// encoding/json.RawMessage is an alias of this with GOEXPERIMENT=jsonv2
+	"encoding/json/jsontext.Value": {kind: builtinClone},
+}
+
+// lookupBuiltin returns the built in way of copying `t`, if there is one.
+func lookupBuiltin(t goType) (builtin, bool) {
+	b, ok := builtins[typeKey(t)]
+	return b, ok
+}
+
+// builtinExpr returns the expression which copies the value `v` of type `t`
+// with `b`, which must be builtinShare or builtinClone.
+func (g *generator) builtinExpr(t goType, b builtin, v string) string {
+	if b.kind == builtinShare {
+		return v
+	}
+	g.addImport(t)
+	return g.getName(t) + "(" + g.importPkg("bytes", "bytes") + ".Clone(" + v + "))"
+}
+
+// writeBuiltin writes the code to copy the value at `t` with `b`.
+func (g *generator) writeBuiltin(buf *bytes.Buffer, t *reflectType, b builtin, copyStr, copyVal, varStr string) {
+	switch b.kind {
+	case builtinShare, builtinClone:
+		if b.kind == builtinShare && isKind(t.parent, reflect.Struct, reflect.Ptr) {
+			// already assigned along with its parent
+			return
+		}
+		fmt.Fprintf(buf, "%s = %s\n", copyStr, g.builtinExpr(t.goType, b, copyVal))
+	case builtinSet:
+		g.addImport(t.Elem())
+		fmt.Fprintf(buf, "if %s != nil {\n%s = new(%s).%s(%s)\n", copyVal, copyStr, g.getName(t.Elem()), b.method, copyVal)
+		writeNilElse(buf, t, copyStr)
+		buf.WriteString("}\n")
+	case builtinDeref:
+		fmt.Fprintf(buf, "if %s != nil {\n%s := *%s\n%s = &%s\n", copyVal, varStr, copyVal, copyStr, varStr)
+		writeNilElse(buf, t, copyStr)
+		buf.WriteString("}\n")
+	}
+}
@@ -3,16 +3,18 @@ package deepcopy
 import (
 	"bytes"
 	"fmt"
+	"go/format"
+	"go/token"
 	"reflect"
 	"strconv"
 	"strings"
 )
 
-// reflect type wrapts reflect.Type with functionality for traversing the type
+// reflect type wrapts a goType with functionality for traversing the type
 // and links it to its parent.
 type reflectType struct {
 	parent *reflectType
-	reflect.Type
+	goType
 
 	// index is a value that is incremented at each walk step for the next type
 	// when the current type is indexable.
@@ -34,11 +36,11 @@ func (t *reflectType) Next() *reflectType {
 
 	switch t.Kind() {
 	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
-		next.Type = t.Elem()
+		next.goType = t.Elem()
 	case reflect.Struct:
 		if t.NumField() > 0 {
 			field := t.Field(0)
-			next.Type = field.Type
+			next.goType = field.Type
 		}
 	default:
 		if t.parent != nil {
@@ -47,7 +49,7 @@ func (t *reflectType) Next() *reflectType {
 				if t.fieldIndex < t.parent.NumField()-1 {
 					next.fieldIndex = t.fieldIndex + 1
 					field := t.parent.Field(next.fieldIndex)
-					next.Type = field.Type
+					next.goType = field.Type
 				}
 			}
 		}
@@ -72,6 +74,12 @@ func getCopyName(root, copyRoot string, t *reflectType) (copyStr, copyVal, varSt
 		return
 	}
 
+	// map values are not addressable, so structs and arrays held in a map are
+	// copied into a variable which is then stored in the map
+	if isKind(t.parent, reflect.Struct, reflect.Array) && isKind(t.parent.parent, reflect.Map) {
+		copyStr = varStr
+	}
+
 	switch t.parent.Kind() {
 	case reflect.Struct:
 		if t.fieldIndex <= t.parent.NumField()-1 {
@@ -84,6 +92,13 @@ func getCopyName(root, copyRoot string, t *reflectType) (copyStr, copyVal, varSt
 		copyVal = "v" + strconv.Itoa(t.parent.index)
 		varStr += strconv.Itoa(t.parent.index)
 	case reflect.Ptr:
+		if t.Kind() != reflect.Struct {
+			// the value has already been copied to the variable allocated by
+			// the pointer, fields of structs can be reached through the
+			// pointer itself
+			copyStr = varStr
+			copyVal = "(*" + copyVal + ")"
+		}
 		varStr += strconv.Itoa(t.index)
 	}
 
@@ -97,43 +112,799 @@ func getCopyName(root, copyRoot string, t *reflectType) (copyStr, copyVal, varSt
 //   - `o` is the object which you want to generate a copy function for
 //   - `ignorePkgErrs` takes a list of objects that you would like to ignore errors related to non-accessible types in other packages
 // It returns the neccessary import statements and the generated copy function to use.
+//
+// It is the same as calling `GenerateWithOptions(o, Receiver(ref), Ignore(ignorePkgErrs...))`.
 func Generate(ref string, o interface{}, ignorePkgErrs []interface{}) (importsBuf []byte, copyFnBuf []byte, err error) {
-	imports := make(map[string]struct{})
+	return GenerateWithOptions(o, Receiver(ref), Ignore(ignorePkgErrs...))
+}
+
+// GenerateWithOptions is used to generate a function for any object that will
+// create a deep copy of that object.
+// `opts` configures the generated function, see `Option`.
+// It returns the neccessary import statements and the generated copy function to use.
+func GenerateWithOptions(o interface{}, opts ...Option) (importsBuf []byte, copyFnBuf []byte, err error) {
+	opt := newOptions(opts)
+	t := reflect.TypeOf(o)
+	if err := checkRootType(t); err != nil {
+		return nil, nil, err
+	}
+	if opt.pointerReceiver && t.Kind() != reflect.Ptr {
+		t = reflect.PtrTo(t)
+	}
+	return generateCopy(fromReflect(t), opt, newImportNames())
+}
+
+// checkRootType returns an error if no copy function can be generated for the
+// type `t` of a live value.
+func checkRootType(t reflect.Type) error {
+	if t == nil {
+		return wrapErr(ErrUnsupportedType, "cannot make copy of nil")
+	}
+	// reflection only knows the type arguments of an instantiated generic
+	// type, not its type parameters, so the method can't be declared
+	if strings.Contains(baseType(t).Name(), "[") {
+		return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot generate copy function for instantiated generic type %s, generate it from source instead", t))
+	}
+	return nil
+}
+
+// generator holds the state shared between the functions generated for a
+// single type: the copy function itself and any helpers it needs.
+type generator struct {
+	options
+
+	rootPkg  string
+	rootName string
+	// imports maps the import paths used by the generated code to the names
+	// they are imported as, see `names`.
+	imports map[string]string
+	names   *importNames
+	// typeParamDecl and typeArgs are the type parameters of a generic root
+	// type, which helper functions are declared with and called with.
+	typeParamDecl, typeArgs string
+	// path is the field path to the value copied by the function currently
+	// being generated, used in errors.
+	path string
+	// errs are the errors for the fields which could not be copied
+	errs []*TypeError
+
+	// funcs is the list of functions known to copy a given type.
+	// Nested values of these types are copied by calling the function rather
+	// than by generating the copy in-line, which is what allows recursive types
+	// to be copied.
+	funcs []copyFunc
+	// helpers holds the helper functions generated for recursive types.
+	helpers *bytes.Buffer
+}
+
+// copyFunc is a generated function which copies values of type `t`.
+type copyFunc struct {
+	t    goType
+	name string
+	// method is set when the function is the `Copy` method of the type rather
+	// than a helper function.
+	method bool
+}
+
+// visitedType is the type of the map threaded through the generated functions
+// to keep track of the references which have already been copied when
+// aliasing is preserved.
+// It is keyed by the pointer itself for pointers, which unlike an address also
+// includes the type being pointed to, and by the address of the data for maps
+// and slices, which are not comparable.
+const visitedType = "map[interface{}]interface{}"
+
+// call returns the code to call the function with `v`.
+// When `deref` is set `v` is a pointer to the value to be copied.
+func (g *generator) call(f copyFunc, v string, deref bool) string {
+	if f.method {
+		return v + "." + g.methodName + "()"
+	}
+	if deref {
+		v = "*" + v
+	}
+	if g.preserveAliasing {
+		v += ", visited"
+	}
+	return f.name + g.typeArgs + "(" + v + ")"
+}
+
+// generateCopy generates the copy function for the passed in type.
+// Packages are imported with the names assigned by `names`.
+func generateCopy(rootType goType, opts options, names *importNames) (importsBuf []byte, copyFnBuf []byte, err error) {
+	g := &generator{
+		options: opts,
+		rootPkg: getPkgName(rootType),
+		imports: make(map[string]string),
+		names:   names,
+		helpers: bytes.NewBuffer(nil),
+	}
+	g.rootName = strings.TrimPrefix(g.getName(rootType), "*")
+	g.typeParamDecl, g.typeArgs = g.typeParams(rootType)
+	if g.typeArgs != "" {
+		// helpers are named after the generic type, without its type
+		// parameters
+		g.rootName = g.rootName[:strings.IndexByte(g.rootName, '[')]
+	}
+	g.path = g.rootName
+
+	if rootType.Kind() == reflect.Struct && containsLock(rootType) {
+		// copying the receiver by value would copy its locks
+		rootType = rootType.PtrTo()
+	}
+	if g.kubernetes {
+		if rootType.Kind() != reflect.Ptr {
+			rootType = rootType.PtrTo()
+		}
+		// nested values of the root type are copied with `DeepCopy`
+		g.methodName = "DeepCopy"
+	}
+
+	ref := g.ref
 	buf := bytes.NewBuffer(nil)
-	root := &reflectType{parent: nil, Type: reflect.TypeOf(o)}
-	rootPkg := getPkgName(root.Type)
-	baseCopy := ref + "Copy"
+	name := g.getName(rootType)
+	signature := "func(" + ref + " " + name + ") " + g.methodName + "() " + name
+	var into string
+	if g.kubernetes {
+		signature = "func(" + ref + " " + name + ") DeepCopyInto(out " + name + ")"
+		into = "out"
+	}
+	if g.preserveAliasing {
+		// The Copy method can't take the visited map, so it calls a helper
+		// which does the actual copy.
+		f := copyFunc{t: rootType, name: "deepCopy_" + getPkgAlias(g.rootName)}
+		g.funcs = append(g.funcs, f)
+		switch {
+		case into != "" && containsLock(rootType.Elem()):
+			fmt.Fprintf(buf, "%s {\n%sCopy := %s%s(%s, make(%s))\n", signature, ref, f.name, g.typeArgs, ref, visitedType)
+			g.writeLockedMove(buf, rootType.Elem(), into, ref+"Copy")
+			buf.WriteString("}\n\n")
+		case into != "":
+			fmt.Fprintf(buf, "%s {\n*%s = *%s%s(%s, make(%s))\n}\n\n", signature, into, f.name, g.typeArgs, ref, visitedType)
+		default:
+			fmt.Fprintf(buf, "%s {\nreturn %s%s(%s, make(%s))\n}\n\n", signature, f.name, g.typeArgs, ref, visitedType)
+		}
+		signature, into = g.funcSignature(f, rootType), ""
+	} else {
+		g.funcs = append(g.funcs, copyFunc{t: rootType, method: true})
+	}
+
+	if err := g.writeFunc(buf, rootType, signature, into); err != nil {
+		return nil, nil, err
+	}
+	if len(g.errs) > 0 && !g.bestEffort {
+		return nil, nil, errorList(g.errs)
+	}
+	if g.kubernetes {
+		g.writeKubernetesMethods(buf, rootType)
+	}
+	buf.Write(g.helpers.Bytes())
+
+	importsW := bytes.NewBuffer(nil)
+	writeImports(importsW, g.imports)
+
+	if !g.formatOutput {
+		return importsW.Bytes(), buf.Bytes(), errorList(g.errs)
+	}
+	if importsBuf, err = format.Source(importsW.Bytes()); err != nil {
+		return nil, nil, fmt.Errorf("error formatting generated imports: %v", err)
+	}
+	if copyFnBuf, err = format.Source(buf.Bytes()); err != nil {
+		return nil, nil, fmt.Errorf("error formatting generated code: %v", err)
+	}
+	return importsBuf, copyFnBuf, errorList(g.errs)
+}
+
+// writeKubernetesMethods writes the `DeepCopy` method, and the
+// `DeepCopyObject` method if it is enabled, for the pointer type `t` which
+// already has a `DeepCopyInto` method.
+func (g *generator) writeKubernetesMethods(buf *bytes.Buffer, t goType) {
+	ref, name := g.ref, g.getName(t)
+	fmt.Fprintf(buf, "\nfunc(%s %s) DeepCopy() %s {\nif %s == nil {\nreturn nil\n}\n", ref, name, name, ref)
+	fmt.Fprintf(buf, "out := new(%s)\n%s.DeepCopyInto(out)\nreturn out\n}\n", g.getName(t.Elem()), ref)
+	if g.objectType == "" {
+		return
+	}
+
+	object := g.objectType
+	if g.objectImportPath != "" && g.objectImportPath != g.rootPkg {
+		object = g.importPkg(g.objectImportPath, "") + "." + object
+	}
+	fmt.Fprintf(buf, "\nfunc(%s %s) DeepCopyObject() %s {\nif c := %s.DeepCopy(); c != nil {\nreturn c\n}\nreturn nil\n}\n", ref, name, object, ref)
+}
+
+// hasDeepCopyInto determines if values of type `t` can be copied with a
+// `DeepCopyInto` method, which is only used for Kubernetes style output.
+// The root type is assumed to have one, since it is being generated.
+func (g *generator) hasDeepCopyInto(t goType) bool {
+	if !g.kubernetes || t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface {
+		return false
+	}
+	if root := g.funcs[0].t; root.Kind() == reflect.Ptr && root.Elem().Identical(t) {
+		return true
+	}
+	ptr := t.PtrTo()
+	m, ok := ptr.MethodByName("DeepCopyInto")
+	return ok && len(m.In) == 1 && len(m.Out) == 0 && m.In[0].Identical(ptr)
+}
+
+// funcFor returns the function to use to copy the type at `t`, if there is one.
+// If `t` is a recursive type a helper function is generated for it so that
+// the recursion point can call it instead of generating code forever.
+func (g *generator) funcFor(t *reflectType) (copyFunc, bool, error) {
+	for _, f := range g.funcs {
+		if f.t.Identical(t.goType) {
+			return f, true, nil
+		}
+	}
+	if t.Name() == "" || t.Kind() == reflect.Ptr || !isRecursive(t) {
+		return copyFunc{}, false, nil
+	}
+
+	f, err := g.helper(t.goType, fieldPath(g.path, t))
+	if err != nil {
+		return copyFunc{}, false, err
+	}
+	return f, true, nil
+}
+
+// fieldPath returns the path to `t` from the root of the function, at `base`,
+// e.g. `Foo.Items[].Owner`.
+func fieldPath(base string, t *reflectType) string {
+	if t.parent == nil {
+		return base
+	}
+	path := fieldPath(base, t.parent)
+	switch t.parent.Kind() {
+	case reflect.Struct:
+		return path + "." + t.parent.Field(t.fieldIndex).Name
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return path + "[]"
+	default:
+		return path
+	}
+}
 
-	ignored := make(map[reflect.Type]bool, len(ignorePkgErrs))
-	for _, i := range ignorePkgErrs {
-		ignored[reflect.TypeOf(i)] = true
+// helper generates a helper function which copies values of type `t`, or
+// returns the existing function if there already is one.
+// `path` is the path to the value which needs the helper.
+func (g *generator) helper(t goType, path string) (copyFunc, error) {
+	for _, f := range g.funcs {
+		if f.t.Identical(t) {
+			return f, nil
+		}
+	}
+
+	name := g.getName(t)
+	if strings.HasPrefix(name, "*") {
+		name = name[1:] + "Ptr"
+	}
+	f := copyFunc{t: t, name: "deepCopy_" + getPkgAlias(g.rootName) + "_" + funcName(getPkgAlias(name))}
+	g.funcs = append(g.funcs, f)
+	g.addImport(t)
+
+	parentPath := g.path
+	g.path = path
+	defer func() { g.path = parentPath }()
+
+	buf := bytes.NewBuffer(nil)
+	if err := g.writeFunc(buf, t, g.funcSignature(f, t), ""); err != nil {
+		return copyFunc{}, err
+	}
+	g.helpers.WriteString("\n")
+	g.helpers.Write(buf.Bytes())
+	return f, nil
+}
+
+// snapshot is the state of the generator at a point in time, so that the
+// changes made by generating code which turns out to be unusable can be undone.
+type snapshot struct {
+	buf                             *bytes.Buffer
+	bufLen, nFuncs, nHelpers, nErrs int
+	imports                         map[string]string
+}
+
+// snapshot records the state of the generator, and of `buf` if it is not nil.
+func (g *generator) snapshot(buf *bytes.Buffer) snapshot {
+	s := snapshot{
+		buf:      buf,
+		nFuncs:   len(g.funcs),
+		nHelpers: g.helpers.Len(),
+		nErrs:    len(g.errs),
+		imports:  make(map[string]string, len(g.imports)),
+	}
+	if buf != nil {
+		s.bufLen = buf.Len()
+	}
+	for p, name := range g.imports {
+		s.imports[p] = name
+	}
+	return s
+}
+
+// restore undoes any changes made since the snapshot `s` was taken.
+func (g *generator) restore(s snapshot) {
+	if s.buf != nil {
+		s.buf.Truncate(s.bufLen)
+	}
+	g.funcs = g.funcs[:s.nFuncs]
+	g.helpers.Truncate(s.nHelpers)
+	g.errs = g.errs[:s.nErrs]
+	g.imports = s.imports
+}
+
+// collect runs `fn`, which writes code to `buf`.
+// If it fails with a TypeError the code it wrote is discarded and the error is
+// recorded rather than returned, so that every problem with a type can be
+// reported at once. In best effort mode a comment is written instead.
+func (g *generator) collect(buf *bytes.Buffer, fn func() error) error {
+	s := g.snapshot(buf)
+	err := fn()
+	typeErr, ok := err.(*TypeError)
+	if !ok {
+		return err
 	}
 
-	var generate func(t *reflectType) error
+	g.restore(s)
+	g.errs = append(g.errs, typeErr)
+	if g.bestEffort {
+		fmt.Fprintf(buf, "// deepcopy: skipped %s\n", typeErr)
+	}
+	return nil
+}
+
+// tryHelper is like helper, but if the type cannot be copied it undoes any
+// changes made while trying to generate the helper and returns false.
+func (g *generator) tryHelper(t goType, path string) (copyFunc, bool) {
+	s := g.snapshot(nil)
+	f, err := g.helper(t, path)
+	if err != nil || len(g.errs) > s.nErrs {
+		g.restore(s)
+		return copyFunc{}, false
+	}
+	return f, true
+}
+
+// writeInterfaceCopy writes the code to copy the dynamic value `copyVal` of
+// the interface type `t` to `copyStr`, which already holds `copyVal`.
+//
+// If the interface has a `Copy` (or `DeepCopy`) method which returns the
+// interface type, it is used. Otherwise a type switch dispatches on the known
+// implementations of the interface, and on any value with a `Copy` or
+// `DeepCopy` method returning the interface type, falling back to the
+// configured policy for anything else.
+// `path` is the path to the interface value.
+func (g *generator) writeInterfaceCopy(buf *bytes.Buffer, t goType, copyStr, copyVal, path string) error {
+	m, hasCopier := copierMethod(t, t)
+	if !hasCopier && g.interfaceFallback == PolicyError {
+		return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot make copy of %s, which can hold values of any type", t))
+	}
+	g.addImport(t)
+	fmt.Fprintf(buf, "if %s != nil {\n", copyVal)
+	if hasCopier {
+		fmt.Fprintf(buf, "%s = %s.%s()\n}\n", copyStr, copyVal, m)
+		return nil
+	}
+
+	name := g.getName(t)
+	fmt.Fprintf(buf, "switch v := %s.(type) {\n", copyVal)
+	for _, impl := range t.Implementers() {
+		if m, ok := copierMethod(impl, t, impl); ok {
+			fmt.Fprintf(buf, "case %s:\n%s = v.%s()\n", g.getName(impl), copyStr, m)
+			continue
+		}
+		if isShallow(impl) {
+			fmt.Fprintf(buf, "case %s:\n", g.getName(impl))
+			continue
+		}
+		if impl.Kind() != reflect.Ptr && containsLock(impl) {
+			// the value, and its locks, would be copied just by taking it
+			// out of the interface, so it is left to the fallback
+			continue
+		}
+		f, ok := g.tryHelper(impl, path+".("+g.getName(impl)+")")
+		if !ok {
+			// leave it to the fallback
+			continue
+		}
+		fmt.Fprintf(buf, "case %s:\n", g.getName(impl))
+		if impl.Kind() == reflect.Ptr {
+			fmt.Fprintf(buf, "if v != nil {\n%s = %s\n}\n", copyStr, g.call(f, "v", false))
+		} else {
+			fmt.Fprintf(buf, "%s = %s\n", copyStr, g.call(f, "v", false))
+		}
+	}
+	for _, m := range []string{"Copy", "DeepCopy"} {
+		fmt.Fprintf(buf, "case interface{ %s() %s }:\n%s = v.%s()\n", m, name, copyStr, m)
+	}
+	switch g.interfaceFallback {
+	case PolicyNil:
+		fmt.Fprintf(buf, "default:\n%s = nil\n", copyStr)
+	case PolicyPanic:
+		fmtPkg := g.importPkg("fmt", "fmt")
+		fmt.Fprintf(buf, "default:\npanic(%s.Sprintf(\"cannot make copy of %%T in %s\", v))\n", fmtPkg, copyVal)
+	}
+	buf.WriteString("}\n}\n")
+	return nil
+}
+
+// policyFor returns the policy to use for the channel or func at `t`.
+// The directive of the struct field which holds `t`, possibly within a slice,
+// map, array or pointer, takes precedence over the global policy.
+func (g *generator) policyFor(t *reflectType) Policy {
+	for n := t; n.parent != nil; n = n.parent {
+		if !isKind(n.parent, reflect.Struct) {
+			continue
+		}
+		if p, err := ParsePolicy(n.parent.Field(n.fieldIndex).Directive); err == nil {
+			return p
+		}
+		break
+	}
+	if t.Kind() == reflect.Chan {
+		return g.chanPolicy
+	}
+	return g.funcPolicy
+}
+
+// writeRefPolicy writes the code to copy the channel or func at `t` according
+// to its policy.
+func (g *generator) writeRefPolicy(buf *bytes.Buffer, t *reflectType, copyStr, copyVal string) error {
+	kind := "channel"
+	if t.Kind() == reflect.Func {
+		kind = "func"
+	}
+
+	p := g.policyFor(t)
+	switch {
+	case p == PolicyError:
+		return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot make copy of %s types", kind))
+	case p == PolicyPanic:
+		return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot panic on %s values, the panic policy only applies to interfaces", kind))
+	case p == PolicyFresh && t.Kind() != reflect.Chan:
+		return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot make a fresh copy of %s types", kind))
+	}
+
+	switch {
+	case t.parent == nil:
+		fmt.Fprintf(buf, "%s := %s\n", copyStr, copyVal)
+	case !isKind(t.parent, reflect.Struct, reflect.Ptr):
+		fmt.Fprintf(buf, "%s = %s\n", copyStr, copyVal)
+	}
+
+	switch p {
+	case PolicyNil:
+		fmt.Fprintf(buf, "%s = nil\n", copyStr)
+	case PolicyFresh:
+		g.addImport(t.goType)
+		fmt.Fprintf(buf, "if %s != nil {\n%s = make(%s, cap(%s))\n}\n", copyVal, copyStr, g.getName(t.goType), copyVal)
+	}
+	return nil
+}
+
+// writeDirective writes the code to copy the struct field at `t` as told by
+// the `zero`, `nil` or `func=<name>` directive `d`.
+func (g *generator) writeDirective(buf *bytes.Buffer, t *reflectType, d directive, copyStr, copyVal string) error {
+	field := t.parent.Field(t.fieldIndex)
+	if getPkgName(t.parent.goType) != g.rootPkg && !token.IsExported(field.Name) {
+		return wrapErr(ErrUnsettableField, fmt.Sprintf("cannot set unexported field of type '%v' in another package: %s", t.parent.goType, copyVal))
+	}
+
+	switch d.kind {
+	case directiveZero:
+		if isKind(t, reflect.Struct, reflect.Array) {
+			// the name of an array is that of its elements
+			named := t.goType
+			for named.Name() == "" && named.Kind() == reflect.Array {
+				named = named.Elem()
+			}
+			if pkg := getPkgName(named); named.Name() != "" && pkg != "" && pkg != g.rootPkg && !token.IsExported(named.Name()) {
+				return wrapErr(ErrUnexportedType, fmt.Sprintf("cannot use type: %s", t.goType))
+			}
+			g.addImport(t.goType)
+		}
+		fmt.Fprintf(buf, "%s = %s\n", copyStr, g.zeroValue(t.goType))
+	case directiveNil:
+		if isTypeParam(t.goType) || !isKind(t, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func, reflect.Interface, reflect.UnsafePointer) {
+			return wrapErr(ErrInvalidTag, fmt.Sprintf("deepcopy tag \"nil\" used on %s field which cannot be nil", t.Kind()))
+		}
+		fmt.Fprintf(buf, "%s = nil\n", copyStr)
+	case directiveFunc:
+		fmt.Fprintf(buf, "%s = %s(%s)\n", copyStr, d.fn, copyVal)
+	}
+	return nil
+}
+
+// copierMethod returns the name of the `Copy` or `DeepCopy` method of `t` if
+// it takes no arguments and returns one of `results`.
+func copierMethod(t goType, results ...goType) (string, bool) {
+	for _, name := range []string{"Copy", "DeepCopy"} {
+		m, ok := t.MethodByName(name)
+		if !ok || len(m.In) != 0 || len(m.Out) != 1 {
+			continue
+		}
+		for _, r := range results {
+			if m.Out[0].Identical(r) {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// isShallow determines if values of the passed in type can be copied by
+// assignment, i.e. they do not contain any references.
+func isShallow(t goType) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !isShallow(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		return isShallow(t.Elem())
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return false
+	default:
+		return true
+	}
+}
+
+// funcSignature returns the signature of the helper function `f`, which copies
+// values of type `t`.
+func (g *generator) funcSignature(f copyFunc, t goType) string {
+	name := g.getName(t)
+	if g.preserveAliasing {
+		return "func " + f.name + g.typeParamDecl + "(" + g.ref + " " + name + ", visited " + visitedType + ") " + name
+	}
+	return "func " + f.name + g.typeParamDecl + "(" + g.ref + " " + name + ") " + name
+}
+
+// visitedKey returns the key used in the visited map for the reference `v` of
+// type `t`.
+func (g *generator) visitedKey(t goType, v string) string {
+	switch t.Kind() {
+	case reflect.Map:
+		return g.importPkg("reflect", "reflect") + ".ValueOf(" + v + ").Pointer()"
+	case reflect.Slice:
+		// slices sharing a backing array are only the same if they are also
+		// the same length
+		return "[2]uintptr{" + g.importPkg("reflect", "reflect") + ".ValueOf(" + v + ").Pointer(), uintptr(len(" + v + "))}"
+	default:
+		return v
+	}
+}
+
+// writeVisitedCheck writes the code to reuse the copy of the reference `copyVal`
+// if it has already been copied.
+// For the root of a function the function returns the previous copy, otherwise
+// it is assigned to `copyStr` and an else block is opened for the caller to
+// copy the value in, which the caller must close.
+func (g *generator) writeVisitedCheck(buf *bytes.Buffer, t *reflectType, copyStr, copyVal string) {
+	key := g.visitedKey(t.goType, copyVal)
+	name := g.getName(t.goType)
+	if t.parent == nil {
+		if isKind(t, reflect.Map, reflect.Slice) {
+			// nil maps and slices all have the same address
+			fmt.Fprintf(buf, "if %s == nil {\nreturn nil\n}\n", copyVal)
+		}
+		fmt.Fprintf(buf, "if v, ok := visited[%s].(%s); ok {\nreturn v\n}\n", key, name)
+		return
+	}
+	fmt.Fprintf(buf, "if v, ok := visited[%s].(%s); ok {\n%s = v\n} else {\n", key, name, copyStr)
+}
+
+// isRecursive determines if the type at `t` is also the type of one of its
+// parents.
+func isRecursive(t *reflectType) bool {
+	for p := t.parent; p != nil; p = p.parent {
+		if p.goType.Identical(t.goType) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFunc writes a function with the passed in signature which returns a
+// deep copy of its argument (or receiver) of type `rootType` to `buf`.
+// If `into` is set the function stores the copy in the pointer `into` instead
+// of returning it.
+func (g *generator) writeFunc(buf *bytes.Buffer, rootType goType, signature, into string) error {
+	ref, rootPkg, ignored := g.ref, g.rootPkg, g.ignored
+	root := &reflectType{parent: nil, goType: rootType}
+	baseCopy := ref + "Copy"
+
+	base := g.path
+
+	var generate, generateNode func(t *reflectType) error
+	// generateField generates the copy of the struct field at `t`
+	generateField := func(t *reflectType) error {
+		field := t.parent.Field(t.fieldIndex)
+		d, err := parseDirective(field.Directive, field.Type)
+		if err != nil {
+			return annotate(err, fieldPath(base, t), t.goType)
+		}
+		switch d.kind {
+		case directiveShallow:
+			// the field was already assigned along with its struct
+			return nil
+		case directiveZero, directiveNil, directiveFunc:
+			copyStr, copyVal, _ := getCopyName(ref, baseCopy, t)
+			return annotate(g.writeDirective(buf, t, d, copyStr, copyVal), fieldPath(base, t), t.goType)
+		}
+
+		if nextPkg := getPkgName(t.goType); nextPkg != "" && nextPkg != rootPkg {
+			name := g.getName(t.goType)
+			if ln := strings.ToLower(name[0:]); ln == name[0:] {
+				if ignored[typeKey(t.goType)] {
+					return nil
+				}
+				return annotate(wrapErr(ErrUnexportedType, fmt.Sprintf("cannot use type: %s", t.goType)), fieldPath(base, t), t.goType)
+			}
+		}
+
+		if curPkg := getPkgName(t.parent.goType); curPkg != rootPkg {
+			if field.Name[0:] == strings.ToLower(field.Name[0:]) && isKind(t, reflect.Map, reflect.Ptr, reflect.Array, reflect.Slice) {
+				if ignored[typeKey(t.parent.goType)] {
+					return nil
+				}
+				_, copyVal, _ := getCopyName(ref, baseCopy, t)
+				err := wrapErr(ErrUnsettableField, fmt.Sprintf("cannot make copy of type '%v' with unexported field in another package: %s", t.parent.goType, copyVal))
+				return annotate(err, fieldPath(base, t), t.goType)
+			}
+		}
+
+		return generate(t)
+	}
 	generate = func(t *reflectType) error {
 		if t == nil {
 			return nil
 		}
-
+		// errors are annotated with the path of the deepest value they
+		// apply to
+		return annotate(generateNode(t), fieldPath(base, t), t.goType)
+	}
+	generateNode = func(t *reflectType) error {
 		copyStr, copyVal, varStr := getCopyName(ref, baseCopy, t)
-		getPkgName(t)
 
-		if root != t && hasCopyMethod(t.Type) {
-			copyStr, copyVal, _ := getCopyName(ref, baseCopy, t)
-			_, err := buf.Write([]byte(copyStr + " = " + copyVal + ".Copy()\n"))
+		if t.Name() == "" && isKind(t, reflect.Struct, reflect.Interface) && strings.Contains(t.String(), ".") {
+			// the name of the type can't be written with the right package
+			// aliases
+			return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot use unnamed type which refers to named types: %s", t.goType))
+		}
+
+		if isTypeParam(t.goType) {
+			g.writeTypeParam(buf, t, copyStr, copyVal)
+			return nil
+		}
+
+		// registered copiers take precedence over any other way of copying
+		// the type, values behind a pointer are passed to them by the pointer
+		if root != t && !isKind(t.parent, reflect.Ptr) {
+			if c, ok := lookupCopier(t.goType); ok {
+				_, err := fmt.Fprintf(buf, "%s = %s\n", copyStr, g.callCopier(c, copyVal))
+				return err
+			}
+		}
+		// structs behind a pointer are copied by the pointer, see below
+		if b, ok := lookupBuiltin(t.goType); ok && root != t && !(isKind(t.parent, reflect.Ptr) && t.Kind() == reflect.Struct) {
+			g.writeBuiltin(buf, t, b, copyStr, copyVal, varStr)
+			return nil
+		}
+
+		switch {
+		case isSyncType(t.goType):
+			return g.writeSyncValue(buf, t, copyStr, copyVal)
+		case t.parent != nil && t.Kind() == reflect.Ptr && isSyncType(t.Elem()):
+			g.writeSyncPointer(buf, t, copyStr, copyVal)
+			return nil
+		case isKind(t, reflect.Slice, reflect.Array, reflect.Map) && containsLock(t.Elem()):
+			return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot make copy of %s, which holds locks by value", t.goType))
+		}
+
+		if root != t && !isKind(t.parent, reflect.Ptr) && g.hasDeepCopyInto(t.goType) {
+			if isKind(t.parent, reflect.Map) {
+				// map values are not addressable
+				_, err := fmt.Fprintf(buf, "var %s %s\n%s.DeepCopyInto(&%s)\n%s = %s\n", varStr, g.getName(t.goType), copyVal, varStr, copyStr, varStr)
+				return err
+			}
+			_, err := fmt.Fprintf(buf, "%s.DeepCopyInto(&%s)\n", copyVal, copyStr)
 			return err
 		}
 
+		// values behind a pointer are handled by the pointer, which needs to
+		// allocate the copy
+		if root != t && !isKind(t.parent, reflect.Ptr) {
+			f, ok, err := g.funcFor(t)
+			if err != nil {
+				return err
+			}
+			if ok {
+				if t.Kind() == reflect.Ptr {
+					fmt.Fprintf(buf, "if %s != nil {\n%s = %s\n", copyVal, copyStr, g.call(f, copyVal, false))
+					writeNilElse(buf, t, copyStr)
+					_, err = buf.WriteString("}\n")
+				} else {
+					_, err = fmt.Fprintf(buf, "%s = %s\n", copyStr, g.call(f, copyVal, false))
+				}
+				return err
+			}
+		}
+
+		// the value behind the root pointer is the root value, which is
+		// being generated
+		isRoot := root == t || (t.parent == root && root.Kind() == reflect.Ptr)
+		// a copy method returning a value would copy any locks in it
+		if !isRoot && t.Kind() != reflect.Interface && !g.generated(t.goType) && !(t.Kind() != reflect.Ptr && containsLock(t.goType)) {
+			name, indirect, err := g.copyMethod(t.goType)
+			if err != nil {
+				return err
+			}
+			if name != "" {
+				call := copyVal + "." + name + "()"
+				switch {
+				case t.Kind() != reflect.Ptr && !indirect:
+					_, err = fmt.Fprintf(buf, "%s = %s\n", copyStr, call)
+				case t.Kind() != reflect.Ptr:
+					_, err = fmt.Fprintf(buf, "%s = *%s\n", copyStr, call)
+				case !indirect:
+					fmt.Fprintf(buf, "if %s != nil {\n%s = %s\n", copyVal, copyStr, call)
+					writeNilElse(buf, t, copyStr)
+					_, err = buf.WriteString("}\n")
+				default:
+					fmt.Fprintf(buf, "if %s != nil {\n%s := %s\n%s = &%s\n", copyVal, varStr, call, copyStr, varStr)
+					writeNilElse(buf, t, copyStr)
+					_, err = buf.WriteString("}\n")
+				}
+				return err
+			}
+		}
+
 		switch t.Kind() {
-		case reflect.Chan:
-			return wrapErr(ErrUnsupportedType, "cannot make copy of channel types")
+		case reflect.Chan, reflect.Func:
+			return g.writeRefPolicy(buf, t, copyStr, copyVal)
+		case reflect.Interface:
+			if t.parent == nil {
+				return wrapErr(ErrUnsupportedType, "cannot generate copy function for interface types")
+			}
+			if !isKind(t.parent, reflect.Struct, reflect.Ptr) {
+				buf.Write([]byte(fmt.Sprintf("%s = %s\n", copyStr, copyVal)))
+			}
+			return g.writeInterfaceCopy(buf, t.goType, copyStr, copyVal, fieldPath(base, t))
 		case reflect.Struct:
 			equals := ":="
 			if t.parent != nil {
 				equals = "="
 			}
 
-			if !isKind(t.parent, reflect.Ptr) {
+			// structs holding locks can't be copied by assignment, so their
+			// fields are assigned one by one leaving the locks zeroed
+			locked := containsLock(t.goType)
+			// the fields of a struct held in a map are copied into a variable,
+			// see getCopyName
+			inMap := isKind(t.parent, reflect.Map) && t.NumField() > 0
+			guard, lock, unlock, err := guardLock(t.goType)
+			if err != nil {
+				return err
+			}
+			switch {
+			case locked:
+				if t.parent == nil {
+					fmt.Fprintf(buf, "var %s %s\n", copyStr, g.getName(t.goType))
+				}
+				if guard != "" {
+					fmt.Fprintf(buf, "%s.%s.%s()\n", copyVal, guard, lock)
+				}
+				if err := g.writeLockedFields(buf, t, copyStr, copyVal); err != nil {
+					return err
+				}
+			case inMap:
+				fmt.Fprintf(buf, "%s := %s\n", varStr, copyVal)
+			case isKind(t.parent, reflect.Struct) && containsLock(t.parent.goType):
+				// already assigned by writeLockedFields
+			case !isKind(t.parent, reflect.Ptr):
 				buf.Write([]byte(fmt.Sprintf("%s %s %s\n", copyStr, equals, copyVal)))
 			}
 
@@ -141,76 +912,109 @@ func Generate(ref string, o interface{}, ignorePkgErrs []interface{}) (importsBu
 			//
 			// TODO(cpuguy83): Why doesn't this work properly in Next()?
 			for i := 0; i < t.NumField(); i++ {
-				field := t.Field(i)
-				if dcTagVal, ok := field.Tag.Lookup("deepcopy"); ok && dcTagVal == "skip" {
-					continue
-				}
-
 				next := &reflectType{
 					parent:     t,
-					Type:       field.Type,
+					goType:     t.Field(i).Type,
 					fieldIndex: i,
 					index:      t.index,
 				}
-				if nextPkg := getPkgName(next.Type); nextPkg != "" && nextPkg != rootPkg {
-					name := getName(next.Type, rootPkg)
-					if ln := strings.ToLower(name[0:]); ln == name[0:] {
-						if ignored[next.Type] {
-							continue
-						}
-						return wrapErr(ErrUnexportedType, fmt.Sprintf("cannot use type: %s", next.Type))
-					}
-				}
-
-				if curPkg := getPkgName(t.Type); curPkg != rootPkg {
-					if field.Name[0:] == strings.ToLower(field.Name[0:]) && isKind(next, reflect.Map, reflect.Ptr, reflect.Array, reflect.Slice) {
-						if ignored[t.Type] {
-							continue
-						}
-						_, copyVal, _ = getCopyName(ref, baseCopy, next)
-						return wrapErr(ErrUnsettableField, fmt.Sprintf("cannot make copy of type '%v' with unexported field in another package: %s", t.Type, copyVal))
-					}
-				}
-
-				if err := generate(next); err != nil {
+				if err := g.collect(buf, func() error { return generateField(next) }); err != nil {
 					return err
 				}
 			}
+			if locked && guard != "" {
+				fmt.Fprintf(buf, "%s.%s.%s()\n", copyVal, guard, unlock)
+			}
+			if inMap {
+				fmt.Fprintf(buf, "%s = %s\n", copyStr, varStr)
+			}
 			return nil
 		case reflect.Ptr:
-			if t.parent == nil {
-				_, err := buf.Write([]byte(fmt.Sprintf("var %s %s\n", varStr, getName(t.Type.Elem(), rootPkg))))
-				if err != nil {
-					return err
-				}
-			} else {
+			if t.parent != nil {
 				buf.Write([]byte(fmt.Sprintf("if %s != nil {", copyVal)))
-				_, err := buf.Write([]byte(fmt.Sprintf("var %s %s\n", varStr, getName(t.Type.Elem(), rootPkg))))
-				if err != nil {
-					return err
-				}
 			}
-			equals := ":="
-			if t.parent != nil || copyStr == varStr {
-				equals = "="
+			if g.preserveAliasing {
+				g.writeVisitedCheck(buf, t, copyStr, copyVal)
 			}
-			buf.Write([]byte(fmt.Sprintf("%s %s *%s\n", varStr, equals, copyVal)))
-			if t.parent != nil {
-				buf.Write([]byte(fmt.Sprintf("%s = &%s\n", copyStr, varStr)))
+			_, err := buf.Write([]byte(fmt.Sprintf("var %s %s\n", varStr, g.getName(t.goType.Elem()))))
+			if err != nil {
+				return err
 			}
 
 			next := t.Next()
-			addImport(next, rootPkg, imports)
-			if err := generate(next); err != nil {
+			f, hasFunc, err := g.funcFor(next)
+			if err != nil {
 				return err
 			}
+			val := "*" + copyVal
+			c, registered := lookupCopier(next.goType)
+			b, isBuiltin := lookupBuiltin(next.goType)
+			isBuiltin = isBuiltin && (b.kind == builtinShare || b.kind == builtinClone)
+			switch {
+			case registered:
+				val = g.callCopier(c, val)
+			case isBuiltin:
+				val = g.builtinExpr(next.goType, b, val)
+			case hasFunc:
+				val = g.call(f, copyVal, true)
+			}
+			hasFunc = hasFunc || registered || isBuiltin
+			// structs holding locks are copied field by field
+			locked := !hasFunc && containsLock(next.goType)
+			into := t.parent != nil && !registered && !isBuiltin && g.hasDeepCopyInto(next.goType)
+
+			if g.preserveAliasing {
+				// the copy must be marked as visited before its fields are
+				// copied in case they refer back to it
+				if t.parent != nil {
+					buf.Write([]byte(fmt.Sprintf("%s = &%s\n", copyStr, varStr)))
+				}
+				buf.Write([]byte(fmt.Sprintf("visited[%s] = &%s\n", copyVal, varStr)))
+				if into {
+					buf.Write([]byte(fmt.Sprintf("%s.DeepCopyInto(&%s)\n", copyVal, varStr)))
+				} else if !locked {
+					buf.Write([]byte(fmt.Sprintf("%s = %s\n", varStr, val)))
+				}
+			} else if into {
+				if t.parent != nil {
+					buf.Write([]byte(fmt.Sprintf("%s = &%s\n", copyStr, varStr)))
+				}
+				buf.Write([]byte(fmt.Sprintf("%s.DeepCopyInto(&%s)\n", copyVal, varStr)))
+			} else {
+				equals := ":="
+				if t.parent != nil || copyStr == varStr {
+					equals = "="
+				}
+				if !locked {
+					buf.Write([]byte(fmt.Sprintf("%s %s %s\n", varStr, equals, val)))
+				}
+				if t.parent != nil {
+					buf.Write([]byte(fmt.Sprintf("%s = &%s\n", copyStr, varStr)))
+				}
+			}
+
+			g.addImport(next.goType)
+			if !hasFunc && !into {
+				if err := generate(next); err != nil {
+					return err
+				}
+			}
+			if g.preserveAliasing && t.parent != nil {
+				buf.Write([]byte{'}', '\n'})
+			}
 			if t.parent != nil {
+				writeNilElse(buf, t, copyStr)
 				_, err = buf.Write([]byte{'}', '\n', '\n'})
 			}
 			return err
 		case reflect.Array:
+			// the elements of an array held in a map are copied into a
+			// variable, see getCopyName
+			inMap := isKind(t.parent, reflect.Map)
 			if t.parent == nil {
-				buf.Write([]byte(fmt.Sprintf("var %s %s\n", varStr, getName(t.Type, rootPkg))))
+				buf.Write([]byte(fmt.Sprintf("var %s %s\n", varStr, g.getName(t.goType))))
+			} else if inMap {
+				fmt.Fprintf(buf, "%s := %s\n", varStr, copyVal)
 			}
 			s := fmt.Sprintf("for i%d, v%d := range %s {\n", t.index, t.index, copyVal)
 			_, err := buf.Write([]byte(s))
@@ -221,19 +1025,32 @@ func Generate(ref string, o interface{}, ignorePkgErrs []interface{}) (importsBu
 				return err
 			}
 			_, err = buf.Write([]byte{'}', '\n'})
+			if inMap {
+				fmt.Fprintf(buf, "%s = %s\n", copyStr, varStr)
+			}
 			return err
 		case reflect.Map, reflect.Slice:
 			next := t.Next()
-			addImport(t, rootPkg, imports)
-			addImport(next, rootPkg, imports)
+			g.addImport(t.goType)
+			g.addImport(next.goType)
 			var s string
-			name := getName(t.Type, rootPkg)
+			name := g.getName(t.goType)
 			if t.parent == nil {
+				if g.preserveAliasing {
+					g.writeVisitedCheck(buf, t, copyStr, copyVal)
+				}
 				s = fmt.Sprintf("%s := make(%s, len(%s))\n", copyStr, name, copyVal)
 			} else {
-				s = fmt.Sprintf(`if %s != nil {
-				%s = make(%s, len(%s))
-				`, copyVal, copyStr, name, copyVal)
+				s = fmt.Sprintf("if %s != nil {\n", copyVal)
+				if g.preserveAliasing {
+					buf.WriteString(s)
+					g.writeVisitedCheck(buf, t, copyStr, copyVal)
+					s = ""
+				}
+				s += fmt.Sprintf("%s = make(%s, len(%s))\n", copyStr, name, copyVal)
+			}
+			if g.preserveAliasing {
+				s += fmt.Sprintf("visited[%s] = %s\n", g.visitedKey(t.goType, copyVal), copyStr)
 			}
 			_, err := buf.Write([]byte(s))
 			if err != nil {
@@ -253,8 +1070,13 @@ func Generate(ref string, o interface{}, ignorePkgErrs []interface{}) (importsBu
 			if err != nil {
 				return err
 			}
+			if g.preserveAliasing && t.parent != nil {
+				buf.Write([]byte{'}', '\n'})
+			}
 			if t.parent != nil {
-				_, err = buf.Write([]byte{'\n', '}', '\n', '\n'})
+				buf.WriteByte('\n')
+				writeNilElse(buf, t, copyStr)
+				_, err = buf.Write([]byte{'}', '\n', '\n'})
 			}
 			return err
 		default:
@@ -271,15 +1093,23 @@ func Generate(ref string, o interface{}, ignorePkgErrs []interface{}) (importsBu
 		}
 	}
 
-	name := getName(root.Type, rootPkg)
-	_, err = buf.Write([]byte("func(" + ref + " " + name + ") Copy() " + name + " {\n"))
-
-	if err != nil {
-		return nil, nil, err
+	if _, err := buf.Write([]byte(signature + " {\n")); err != nil {
+		return err
 	}
 
 	if err := generate(root); err != nil {
-		return nil, nil, err
+		return err
+	}
+
+	if into != "" {
+		buf.WriteByte('\n')
+		if containsLock(rootType.Elem()) {
+			g.writeLockedMove(buf, rootType.Elem(), into, baseCopy)
+		} else {
+			buf.Write([]byte("*" + into + " = " + baseCopy + "\n"))
+		}
+		buf.Write([]byte{'}', '\n'})
+		return nil
 	}
 
 	buf.Write([]byte("\nreturn "))
@@ -288,25 +1118,23 @@ func Generate(ref string, o interface{}, ignorePkgErrs []interface{}) (importsBu
 	}
 	buf.Write([]byte(baseCopy + "\n"))
 	buf.Write([]byte{'}', '\n'})
+	return nil
+}
 
-	importsW := bytes.NewBuffer(nil)
-	if len(imports) > 0 {
-		importsW.Write([]byte("import (\n"))
-	}
-	for i := range imports {
-		alias := getPkgAlias(i)
-		importsW.Write([]byte(alias + " " + `"` + i + `"` + "\n"))
+// writeNilElse writes an else block for the `if copyVal != nil` check around
+// the copy of the value at `t`, which sets `copyStr` to nil, if the value is
+// held in a map: unlike the elements of slices and the fields of structs the
+// entry does not exist in the copy until it is set.
+func writeNilElse(buf *bytes.Buffer, t *reflectType, copyStr string) {
+	if isKind(t.parent, reflect.Map) {
+		fmt.Fprintf(buf, "} else {\n%s = nil\n", copyStr)
 	}
-	if len(imports) > 0 {
-		importsW.Write([]byte{'\n', ')', '\n'})
-	}
-	return importsW.Bytes(), buf.Bytes(), nil
 }
 
 // isKind is a helper function that returns true if the passed in type matches
 // any of the passed in kinds.
 func isKind(t *reflectType, kinds ...reflect.Kind) bool {
-	if t == nil || t.Type == nil {
+	if t == nil || t.goType == nil {
 		return false
 	}
 
@@ -320,50 +1148,87 @@ func isKind(t *reflectType, kinds ...reflect.Kind) bool {
 	return false
 }
 
-// addImport adds the package of the passed in type to the list of imports if
-// the type is in a different package then the root object.
-func addImport(t reflect.Type, rootPkg string, imports map[string]struct{}) {
-	if name := getPkgName(t); name != rootPkg {
-		if pkgPath := t.PkgPath(); pkgPath != "" {
-			imports[pkgPath] = struct{}{}
+// copyMethod finds a method which creates a deep copy of values of type `t`.
+// It's used to determine if the generator needs to generate it's own copy code
+// in-line with the root object, or if it can just rely on the method to create
+// a deep-copy.
+//
+// Methods are looked up by the conventional names `Copy`, `DeepCopy` and
+// `Clone`, as well as the name of the method being generated, with either a
+// value or a pointer receiver. The method must take no arguments and return
+// either a `T` or a `*T`, `indirect` is set when it is not the same as `t`.
+// Methods promoted from an embedded field which return the embedded type are
+// ignored.
+//
+// If no method is found but the type has a method with the name of the method
+// being generated (or `Copy`) with another signature, an error is returned
+// rather than silently generating code which doesn't use it.
+func (g *generator) copyMethod(t goType) (name string, indirect bool, err error) {
+	base, ptr := t, t
+	if t.Kind() == reflect.Ptr {
+		base = t.Elem()
+	} else {
+		ptr = t.PtrTo()
+	}
+
+	for _, name := range []string{g.methodName, "Copy", "DeepCopy", "Clone"} {
+		m, ok := ptr.MethodByName(name)
+		if !ok {
+			continue
+		}
+		if len(m.In) == 0 && len(m.Out) == 1 {
+			switch {
+			case m.Out[0].Identical(t):
+				return name, false, nil
+			case m.Out[0].Identical(base), m.Out[0].Identical(ptr):
+				return name, true, nil
+			}
+		}
+		if err == nil && !m.Promoted && (name == "Copy" || name == g.methodName) {
+			err = wrapErr(ErrCopyMethod, fmt.Sprintf("%s has method %s, which does not return %s", t, signatureString(m), base))
 		}
 	}
+	return "", false, err
 }
 
-// hasCopyMethod determines if the passed in type has a deep copy method generated
-// for it.
-// In practice, it checks the type for a function signature like so:
-//    func(<T>) Copy() <T>
-// It's used to determine if the generator needs to generate it's own copy code
-// in-line with the root object, or if it can just rely on the the `Copy()` fn to
-// create a deep-copy.
-func hasCopyMethod(t reflect.Type) bool {
-	m, ok := t.MethodByName("Copy")
-	if !ok || m.Type.NumIn() != 1 || m.Type.NumOut() != 1 {
-		return false
+// signatureString returns the method `m` as it would be written in an
+// interface.
+func signatureString(m method) string {
+	var in, out []string
+	for _, t := range m.In {
+		in = append(in, t.String())
 	}
-	has := m.Type.In(0) == t && m.Type.Out(0) == t
-	if !has {
-		panic(fmt.Sprintf("%v - %v", m.Type.Out(0), t))
+	for _, t := range m.Out {
+		out = append(out, t.String())
+	}
+	s := m.Name + "(" + strings.Join(in, ", ") + ")"
+	switch len(out) {
+	case 0:
+		return s
+	case 1:
+		return s + " " + out[0]
+	default:
+		return s + " (" + strings.Join(out, ", ") + ")"
 	}
-	return has
 }
 
-// canonicalPkgName gets the alias used to refer to the package that the passed
-// in type belongs to.
-// If the type belongs to the same package as the root object, then an empty string
-// is returned.
-func canonicalPkgName(t reflect.Type, rootPkg string) string {
-	pkgName := getPkgName(t)
-	if pkgName == rootPkg || pkgName == "" {
-		pkgName = ""
+// generated determines if a copy function is being generated for the type
+// `t`, or the type it points to.
+// Functions which are being generated are preferred over any existing method,
+// which may be an earlier version of the same function.
+func (g *generator) generated(t goType) bool {
+	for _, f := range g.funcs {
+		if f.t.Identical(t) ||
+			(t.Kind() == reflect.Ptr && f.t.Identical(t.Elem())) ||
+			(f.t.Kind() == reflect.Ptr && f.t.Elem().Identical(t)) {
+			return true
+		}
 	}
-	return getPkgAlias(pkgName)
+	return false
 }
 
-// getPkgAlias converts an package path to an alias name.
-// This makes it easier to refer to types in other packages by providing a
-// consistent naming scheme.
+// getPkgAlias converts a package path, or the name of a type, into something
+// which can be used in an identifier.
 func getPkgAlias(s string) string {
 	alias := strings.Replace(s, "/", "_", -1)
 	alias = strings.Replace(alias, "-", "_", -1)
@@ -373,40 +1238,60 @@ func getPkgAlias(s string) string {
 
 // getName is a recursive function that generates the name of the given type
 // It traverses maps, slices, and pointers as needed.
-func getName(t reflect.Type, rootPkg string) string {
-	pkgName := canonicalPkgName(t, rootPkg)
+func (g *generator) getName(t goType) string {
+	pkgName := g.pkgAlias(t)
 	if n := t.Name(); n != "" {
 		if pkgName != "" {
 			pkgName += "."
 		}
+		if args := t.TypeArgs(); len(args) > 0 {
+			names := make([]string, 0, len(args))
+			for _, arg := range args {
+				names = append(names, g.getName(arg))
+			}
+			n += "[" + strings.Join(names, ", ") + "]"
+		}
 		return pkgName + n
 	}
 	switch t.Kind() {
 	case reflect.Slice:
-		return "[]" + getName(t.Elem(), rootPkg)
+		return "[]" + g.getName(t.Elem())
+	case reflect.Array:
+		return "[" + strconv.Itoa(t.Len()) + "]" + g.getName(t.Elem())
 	case reflect.Map:
 		var key, elem string
-		key = getName(t.Key(), rootPkg)
-		elem = getName(t.Elem(), rootPkg)
+		key = g.getName(t.Key())
+		elem = g.getName(t.Elem())
 		return "map[" + key + "]" + elem
 	case reflect.Ptr:
-		return "*" + getName(t.Elem(), rootPkg)
+		return "*" + g.getName(t.Elem())
+	case reflect.Chan:
+		elem := g.getName(t.Elem())
+		switch t.ChanDir() {
+		case reflect.RecvDir:
+			return "<-chan " + elem
+		case reflect.SendDir:
+			return "chan<- " + elem
+		}
+		if t.Elem().Kind() == reflect.Chan && t.Elem().Name() == "" && t.Elem().ChanDir() == reflect.RecvDir {
+			elem = "(" + elem + ")"
+		}
+		return "chan " + elem
 	default:
 		return t.String()
 	}
 }
 
 // getPkgName gets the package name that the passed in type belongs to
-func getPkgName(t reflect.Type) string {
+func getPkgName(t goType) string {
 	for {
 		if name := t.PkgPath(); name != "" {
 			return name
 		}
-		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array && t.Kind() != reflect.Ptr && t.Kind() != reflect.Map {
-			if t.Name() != "" || !strings.Contains(t.String(), ".") {
-				return ""
-			}
-			panic(fmt.Sprintf("got unexpected type %v", t))
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array && t.Kind() != reflect.Ptr && t.Kind() != reflect.Map && t.Kind() != reflect.Chan {
+			// unnamed types, such as funcs and anonymous structs, can refer
+			// to any number of packages
+			return ""
 		}
 		t = t.Elem()
 	}
@@ -2,6 +2,7 @@ package deepcopy
 
 import (
 	"bytes"
+	"errors"
 	"go/format"
 	"testing"
 
@@ -27,6 +28,9 @@ func TestGenerate(t *testing.T) {
 		{"A simple map type", mapType{}, mapTypeX, nil, nil},
 		{"A map of slices", mapOfSlices{}, mapOfSlicesX, nil, nil},
 		{"A map of maps", mapOfMaps{}, mapOfMapsX, nil, nil},
+		{"A map of arrays", mapOfArrays{}, mapOfArraysX, nil, nil},
+		{"A struct with arrays in other types", structWithArrays{}, structWithArraysX, nil, nil},
+		{"A struct with imported arrays in other types", structWithImportedArrays{}, structWithImportedArraysX, nil, nil},
 		{"A simple struct", simpleStruct{}, simpleStructX, nil, nil},
 		{"A struct with an embedded struct pointer", structWithEmbeddedPointer{}, structWithEmbeddedPointerX, nil, nil},
 		{"A struct pointer", &simpleStruct{}, structPointerX, nil, nil},
@@ -45,12 +49,33 @@ func TestGenerate(t *testing.T) {
 		{"A struct that uses an imported custom slice type", structWithImportedCustomSliceType{}, structWithImportedCustomSliceTypeX, nil, nil},
 		{"A struct type with a channel", structWithChannel{}, nil, ErrUnsupportedType, nil},
 		{"A struct with a skipped field", structWithSkip{}, structWithSkipX, nil, nil},
+		{"A struct with standard library types", structWithStdlibTypes{}, structWithStdlibTypesX, nil, nil},
+		{"A struct with locks", structWithLocks{}, structWithLocksX, nil, nil},
+		{"A struct with locks in a slice", structWithLocksInSlice{}, nil, ErrUnsupportedType, nil},
+		{"A struct with a guard tag on a WaitGroup", structWithBadGuard{}, nil, ErrInvalidTag, nil},
+		{"A struct with deepcopy tags", structWithTags{}, structWithTagsX, nil, nil},
+		{"A struct with zero tags on imported types", structWithZeroTagsOnImports{}, structWithZeroTagsOnImportsX, nil, nil},
+		{"A struct with an unknown deepcopy tag", structWithUnknownTag{}, nil, ErrInvalidTag, nil},
+		{"A struct with a nil deepcopy tag on a value field", structWithNilTagOnValue{}, nil, ErrInvalidTag, nil},
+		{"A struct with a policy deepcopy tag on a field without channels", structWithPolicyTagOnValue{}, nil, ErrInvalidTag, nil},
+		{"A recursive struct", linkedList{}, linkedListX, nil, nil},
+		{"A recursive struct pointer", &ptrTree{}, ptrTreePointerX, nil, nil},
+		{"A recursive map", nestedMap{}, nestedMapX, nil, nil},
+		{"A struct with a recursive field type", tree{}, treeX, nil, nil},
+		{"A struct with a value and pointer receiver Copy methods", structWithPtrReceiverCopy{}, structWithPtrReceiverCopyX, nil, nil},
+		{"A struct with an embedded field which has a Copy method", embedsCopier{}, embedsCopierX, nil, nil},
+		{"A struct with a Clone method", structWithCloner{}, structWithClonerX, nil, nil},
+		{"A struct with a Copy method with the wrong signature", structWithBadCopier{}, nil, ErrCopyMethod, nil},
+		{"A struct with interface fields", structWithInterfaces{}, structWithInterfacesX, nil, nil},
+		{"A struct with an empty interface field", structWithEmptyInterface{}, structWithEmptyInterfaceX, nil, nil},
+		{"A struct with a map of empty interfaces", structWithInterfaceMap{}, structWithInterfaceMapX, nil, nil},
+		{"A struct with channel and func fields", worker{}, nil, ErrUnsupportedType, nil},
 	}
 
 	for _, c := range cases {
 		t.Run(c.explain, func(t *testing.T) {
 			imports, copyFunc, err := Generate("o", c.test, c.ignore)
-			if err := cause(err); err != c.err {
+			if !errors.Is(err, c.err) {
 				t.Fatalf("%s: expected '%v', got: %v", c.explain, c.err, err)
 			}
 
@@ -69,3 +94,59 @@ func TestGenerate(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateWithOptions(t *testing.T) {
+	opts := []Option{Receiver("s"), MethodName("Clone"), PointerReceiver(), FormatOutput()}
+	imports, copyFunc, err := GenerateWithOptions(structWithImports{}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := append(imports, copyFunc...); !bytes.Equal(actual, structWithImportsCloneX) {
+		t.Fatalf("expected: \n%s\n\ngot: \n%s\n\n", structWithImportsCloneX, actual)
+	}
+
+	if _, _, err := GenerateWithOptions(structWithImportsAndUnsettableFields{}, Ignore(fixtures.Banana{})); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := GenerateWithOptions(nil); cause(err) != ErrUnsupportedType {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+	// generic types can only be generated from source
+	if _, _, err := GenerateWithOptions(genericSet[string]{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+	if _, err := GenerateFile("deepcopy", simpleStruct{}, &genericSet[int]{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+}
+
+func TestGenerateKubernetesStyle(t *testing.T) {
+	imports, copyFunc, err := GenerateWithOptions(kubeObject{}, Receiver("in"), DeepCopyObject("Object", "k8s.io/apimachinery/pkg/runtime"), FormatOutput())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := append(imports, copyFunc...); !bytes.Equal(actual, kubeObjectX) {
+		t.Fatalf("expected: \n%s\n\ngot: \n%s\n\n", kubeObjectX, actual)
+	}
+}
+
+func TestGenerateKubernetesStyleWithLocks(t *testing.T) {
+	for _, c := range []struct {
+		explain string
+		opts    []Option
+		x       []byte
+	}{
+		{"DeepCopyInto with locks", nil, kubeLockedX},
+		{"DeepCopyInto with locks preserving aliasing", []Option{PreserveAliasing()}, kubeLockedAliasingX},
+	} {
+		t.Run(c.explain, func(t *testing.T) {
+			imports, copyFunc, err := GenerateWithOptions(kubeLocked{}, append([]Option{Receiver("in"), KubernetesStyle(), FormatOutput()}, c.opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := append(imports, copyFunc...); !bytes.Equal(bytes.TrimSpace(actual), bytes.TrimSpace(c.x)) {
+				t.Fatalf("%s: expected: \n%s\n\ngot: \n%s\n\n", c.explain, c.x, actual)
+			}
+		})
+	}
+}
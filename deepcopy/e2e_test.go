@@ -0,0 +1,202 @@
+package deepcopy
+
+import (
+	"errors"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// e2eVariant is a set of options to generate the fixture types with for the
+// end to end test, along with any extra test code to run against them.
+type e2eVariant struct {
+	name  string
+	opts  []Option
+	tests string
+}
+
+var e2eVariants = []e2eVariant{
+	{name: "default"},
+	{name: "pointer-receiver", opts: []Option{PointerReceiver()}},
+	{name: "preserve-aliasing", opts: []Option{PreserveAliasing()}, tests: `
+func TestAliasing(t *testing.T) {
+	root := &treeNode{Labels: map[string]string{"a": "b"}}
+	root.Children = []*treeNode{{Parent: root}, {Parent: root}}
+	c := tree{Root: root}.Copy()
+	if c.Root == root || c.Root.Children[0].Parent != c.Root || c.Root.Children[1].Parent != c.Root {
+		t.Fatalf("the cycle in the tree was not reproduced: %+v", c.Root)
+	}
+
+	s := sharedRefs{A: []string{"a"}, M: map[string]*simpleStruct{"a": {}}}
+	s.B = s.A
+	s.M["b"] = s.M["a"]
+	sc := s.Copy()
+	sc.A[0] = "b"
+	if sc.B[0] != "b" || s.A[0] != "a" {
+		t.Fatalf("the shared slice is not shared in the copy only: %q %q", s.A, sc.B)
+	}
+	if sc.M["a"] != sc.M["b"] || sc.M["a"] == s.M["a"] {
+		t.Fatal("the shared pointer is not shared in the copy only")
+	}
+}
+`},
+	{name: "kubernetes", opts: []Option{KubernetesStyle()}},
+}
+
+// e2eUnsupported are the fixture types which exist to test the errors of the
+// generator, along with the error they are expected to fail with, so they are
+// left out of the end to end test.
+var e2eUnsupported = map[string]error{
+	"pathItem":                             ErrUnsupportedType,
+	"pathOwner":                            ErrUnsupportedType,
+	"pathRoot":                             ErrUnsupportedType,
+	"structWithAnonymousStruct":            ErrUnsupportedType,
+	"structWithBadCopier":                  ErrCopyMethod,
+	"structWithBadGuard":                   ErrInvalidTag,
+	"structWithChannel":                    ErrUnsupportedType,
+	"structWithImportsAndUnsettableFields": ErrUnsettableField,
+	"structWithLocksInSlice":               ErrUnsupportedType,
+	"structWithNilTagOnTypeParam":          ErrInvalidTag,
+	"structWithNilTagOnValue":              ErrInvalidTag,
+	"structWithPolicyTagOnValue":           ErrInvalidTag,
+	"structWithRegisteredCopiers":          ErrUnsettableField,
+	"structWithUnexportedImportTypes":      ErrUnexportedType,
+	"structWithUnknownTag":                 ErrInvalidTag,
+	"worker":                               ErrUnsupportedType,
+}
+
+// TestEndToEnd generates the code, and the tests (see `GenerateTestFile`), for
+// every fixture type which the generator can handle, writes them to a
+// temporary module next to the fixtures, and vets it and runs the tests with
+// the local go toolchain, so that code which does not compile, copies locks,
+// or does not copy the values it should, is caught.
+// Nothing is downloaded: the module only uses the standard library.
+func TestEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the generated code")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go toolchain is not available")
+	}
+
+	pkg := loadFixtures(t)
+	fixtures, err := ioutil.ReadFile("fixtures_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixturesPkg, err := ioutil.ReadFile(filepath.Join("fixtures", "foo.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range e2eVariants {
+		v := v
+		t.Run(v.name, func(t *testing.T) {
+			t.Parallel()
+
+			names := e2eTypeNames(t, pkg, v)
+			f, err := pkg.GenerateFile(names, v.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src, err := f.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tf, err := pkg.GenerateTestFile(names, v.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testSrc, err := tf.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dir, err := ioutil.TempDir("", "deepcopy-e2e")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			// the fixtures are declared in a test file, so the code generated
+			// for them goes into test files as well
+			files := map[string][]byte{
+				"go.mod":                                 []byte("module github.com/cpuguy83/go-generate\n\ngo 1.21\n"),
+				"deepcopy/fixtures/foo.go":               fixturesPkg,
+				"deepcopy/fixtures_test.go":              fixtures,
+				"deepcopy/zz_deepcopy_generated_test.go": src,
+				"deepcopy/zz_deepcopy_test.go":           testSrc,
+			}
+			if v.tests != "" {
+				files["deepcopy/e2e_test.go"] = []byte("package deepcopy\n\nimport \"testing\"\n" + v.tests)
+			}
+			for name, content := range files {
+				name = filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(name, content, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// go test only runs some of the vet checks, and not the one for
+			// copied locks
+			for _, args := range [][]string{{"vet", "./deepcopy/"}, {"test", "-count=1", "./deepcopy/"}} {
+				cmd := exec.Command(goBin, args...)
+				cmd.Dir = dir
+				cmd.Env = append(os.Environ(), "GO111MODULE=on", "GOFLAGS=", "GOPROXY=off", "GOWORK=off", "GOTOOLCHAIN=local")
+				if out, err := cmd.CombinedOutput(); err != nil {
+					t.Fatalf("go %s: %v: %s", args[0], err, out)
+				}
+			}
+		})
+	}
+}
+
+// e2eTypeNames returns the names of the fixture types to generate the code
+// for with the options of `v`: every type which does not already have a copy
+// method. The types in `e2eUnsupported` must fail with their error, and any
+// other type the generator can't handle fails the test.
+func e2eTypeNames(t *testing.T, pkg *Package, v e2eVariant) []string {
+	opt := newOptions(v.opts)
+	methods := []string{opt.methodName}
+	if opt.kubernetes {
+		methods = []string{"DeepCopy", "DeepCopyInto"}
+	}
+
+	var names []string
+	scope := pkg.pkg.Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || types.IsInterface(obj.Type()) {
+			continue
+		}
+		hasMethod := false
+		mset := types.NewMethodSet(types.NewPointer(obj.Type()))
+		for _, m := range methods {
+			if mset.Lookup(nil, m) != nil {
+				hasMethod = true
+			}
+		}
+		if hasMethod {
+			continue
+		}
+		_, _, err := pkg.GenerateWithOptions(name, v.opts...)
+		if expected, ok := e2eUnsupported[name]; ok {
+			if !errors.Is(err, expected) {
+				t.Fatalf("expected %s to fail with '%v', got: %v", name, expected, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
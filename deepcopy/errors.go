@@ -3,6 +3,8 @@ package deepcopy
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 )
 
 // errors used by this package
@@ -10,25 +12,115 @@ var (
 	ErrUnexportedType  = errors.New("use of unexported type from another package")
 	ErrUnsettableField = errors.New("use of imported type with an unexported field")
 	ErrUnsupportedType = errors.New("unsupported type")
+	ErrCopyMethod      = errors.New("copy method has an unexpected signature")
+	ErrInvalidTag      = errors.New("invalid deepcopy tag")
+	ErrStale           = errors.New("generated code is out of date")
 )
 
-// typeError implements causer to integrate with the github.com/pkg/errors API
-// without having to import the package.
-type typeError struct {
-	err error
+// TypeError is the error returned when a copy function cannot be generated for
+// a type.
+// It implements causer to integrate with the github.com/pkg/errors API without
+// having to import the package, as well as `Unwrap` for `errors.Is`.
+type TypeError struct {
+	// Err is the category of the error, e.g. `ErrUnsupportedType`.
+	Err error
+	// Path is the path to the offending field from the type being generated
+	// for, e.g. `Foo.Items[].Owner`.
+	Path string
+	// Type is the offending type.
+	// It is nil when generating from source, see `TypeName`.
+	Type reflect.Type
+	// TypeName is the name of the offending type.
+	TypeName string
+
 	msg string
 }
 
-func (e *typeError) Cause() error {
-	return e.err
+// Cause returns the category of the error.
+func (e *TypeError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the category of the error.
+func (e *TypeError) Unwrap() error {
+	return e.Err
 }
 
-func (e *typeError) Error() string {
-	return fmt.Sprintf("%s: %s", e.msg, e.err.Error())
+func (e *TypeError) Error() string {
+	s := fmt.Sprintf("%s: %s", e.msg, e.Err.Error())
+	if e.Path != "" {
+		s = e.Path + ": " + s
+	}
+	return s
 }
 
 func wrapErr(err error, msg string) error {
-	return &typeError{err: err, msg: msg}
+	return &TypeError{Err: err, msg: msg}
+}
+
+// annotate sets the path and type of `err` if it is a `TypeError` which does
+// not have them yet.
+func annotate(err error, path string, t goType) error {
+	e, ok := err.(*TypeError)
+	if !ok || e.Path != "" {
+		return err
+	}
+	e.Path = path
+	e.TypeName = t.String()
+	if rt, ok := t.(reflectGoType); ok {
+		e.Type = rt.Type
+	}
+	return e
+}
+
+// Errors is returned when more than one field of a type cannot be copied.
+type Errors []*TypeError
+
+func (e Errors) Error() string {
+	s := make([]string, 0, len(e))
+	for _, err := range e {
+		s = append(s, err.Error())
+	}
+	return fmt.Sprintf("%d errors:\n\t%s", len(e), strings.Join(s, "\n\t"))
+}
+
+// Unwrap returns the errors in the list, for `errors.Is` and `errors.As`.
+func (e Errors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// errorList returns the passed in errors as a single error. A single error is
+// returned as is.
+func errorList(errs []*TypeError) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return Errors(errs)
+	}
+}
+
+// StaleError is returned by `Verify` when the generated code is out of date.
+// It unwraps to `ErrStale`.
+type StaleError struct {
+	// Diff is a unified diff from the existing code to the code generated
+	// now.
+	Diff string
+}
+
+// Unwrap returns `ErrStale`.
+func (e *StaleError) Unwrap() error {
+	return ErrStale
+}
+
+func (e *StaleError) Error() string {
+	return ErrStale.Error() + ":\n" + e.Diff
 }
 
 type causer interface {
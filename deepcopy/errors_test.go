@@ -0,0 +1,68 @@
+package deepcopy
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTypeError(t *testing.T) {
+	_, _, err := Generate("o", pathRoot{}, nil)
+	if !errors.Is(err, ErrUnsupportedType) || cause(err) != ErrUnsupportedType {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+
+	var e *TypeError
+	if !errors.As(err, &e) {
+		t.Fatalf("expected a TypeError, got: %T", err)
+	}
+	if e.Path != "pathRoot.Items[].Owner.done" {
+		t.Fatalf("unexpected path: %s", e.Path)
+	}
+	if e.Type != reflect.TypeOf(make(chan int)) || e.TypeName != "chan int" {
+		t.Fatalf("unexpected type: %v (%s)", e.Type, e.TypeName)
+	}
+
+	_, _, err = loadFixtures(t).Generate("o", "pathRoot", nil)
+	if !errors.As(err, &e) {
+		t.Fatalf("expected a TypeError, got: %T", err)
+	}
+	if e.Path != "pathRoot.Items[].Owner.done" || e.Type != nil || e.TypeName != "chan int" {
+		t.Fatalf("unexpected error: %#v", e)
+	}
+}
+
+func TestTypeErrorUnnamedType(t *testing.T) {
+	_, _, err := Generate("o", structWithAnonymousStruct{}, nil)
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+	if _, _, err := loadFixtures(t).Generate("o", "structWithAnonymousStruct", nil); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, _, err := Generate("o", worker{}, nil)
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expected Errors, got: %T: %v", err, err)
+	}
+	if len(errs) != 2 || errs[0].Path != "worker.done" || errs[1].Path != "worker.errs" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+}
+
+func TestBestEffort(t *testing.T) {
+	imports, copyFunc, err := GenerateWithOptions(worker{}, BestEffort(), FormatOutput())
+	if _, ok := err.(Errors); !ok {
+		t.Fatalf("expected Errors, got: %T: %v", err, err)
+	}
+	if actual := append(imports, copyFunc...); !bytes.Equal(actual, workerBestEffortX) {
+		t.Fatalf("expected: \n%s\n\ngot: \n%s\n\n", workerBestEffortX, actual)
+	}
+}
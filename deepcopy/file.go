@@ -0,0 +1,287 @@
+package deepcopy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/build/constraint"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// header marks generated files as such, see https://go.dev/s/generatedcode.
+const header = "// Code generated by deepcopy. DO NOT EDIT.\n"
+
+// File is a complete go file holding the code generated for one or more types,
+// with the imports of all of them merged into a single import block.
+type File struct {
+	pkgName   string
+	buildTags string
+	// imports maps import paths to the names they are imported as
+	imports map[string]string
+	code    bytes.Buffer
+}
+
+// NewFile returns an empty file in the package named `pkgName`.
+// The only option which applies to files is `BuildTags`.
+func NewFile(pkgName string, opts ...Option) *File {
+	o := newOptions(opts)
+	return &File{
+		pkgName:   pkgName,
+		buildTags: o.buildTags,
+		imports:   make(map[string]string),
+	}
+}
+
+// Add adds the imports and the copy functions generated for a type, as
+// returned by `Generate` and the like, to the file.
+// It is an error for the same name to be used for different imports, which
+// can happen when the code is generated from live values (see
+// `GenerateFile`); the code generated for a `Package` never does that.
+func (f *File) Add(importsBuf, copyFnBuf []byte) error {
+	if len(bytes.TrimSpace(importsBuf)) > 0 {
+		imports, err := parseImports(importsBuf)
+		if err != nil {
+			return err
+		}
+		for p, name := range imports {
+			for other, otherName := range f.imports {
+				if name == otherName && p != other {
+					return fmt.Errorf("cannot import both %s and %s as %s", other, p, name)
+				}
+			}
+		}
+		for p, name := range imports {
+			f.imports[p] = name
+		}
+	}
+
+	f.code.WriteString("\n")
+	f.code.Write(copyFnBuf)
+	return nil
+}
+
+// parseImports returns the import paths in the import block `importsBuf`,
+// mapped to the names they are imported as.
+func parseImports(importsBuf []byte) (map[string]string, error) {
+	src := append([]byte("package p\n"), importsBuf...)
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ImportsOnly)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing generated imports: %v", err)
+	}
+
+	imports := make(map[string]string, len(file.Imports))
+	for _, spec := range file.Imports {
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		// see `writeImports`
+		name := path.Base(p)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[p] = name
+	}
+	return imports, nil
+}
+
+// Bytes returns the formatted source of the file.
+func (f *File) Bytes() ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(header)
+	if f.buildTags != "" {
+		line := "//go:build " + f.buildTags
+		if _, err := constraint.Parse(line); err != nil {
+			return nil, fmt.Errorf("invalid build tags %q: %v", f.buildTags, err)
+		}
+		buf.WriteString("\n" + line + "\n")
+	}
+	fmt.Fprintf(buf, "\npackage %s\n\n", f.pkgName)
+	writeImports(buf, f.imports)
+	buf.Write(f.code.Bytes())
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error formatting generated code: %v\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+// WriteTo writes the formatted source of the file to `w`.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	src, err := f.Bytes()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(src)
+	return int64(n), err
+}
+
+// GenerateFile generates the copy functions for each of the passed in
+// objects into a single, formatted, file in the package named `pkg`.
+// The functions are ordered by type name, so the file is the same regardless
+// of the order of `objs`. A type and a pointer to it can't both be passed in.
+//
+// It is the same as calling `GenerateFileWithOptions(pkg, objs)`.
+func GenerateFile(pkg string, objs ...interface{}) ([]byte, error) {
+	return GenerateFileWithOptions(pkg, objs)
+}
+
+// GenerateFileWithOptions is like `GenerateFile`, configured by `opts`.
+// The errors for the fields which cannot be copied are reported together for
+// all of the objects. With the `BestEffort` option the file is returned along
+// with them, as long as some code could be generated for every object.
+func GenerateFileWithOptions(pkg string, objs []interface{}, opts ...Option) ([]byte, error) {
+	opt := newOptions(opts)
+	rootTypes, err := fileRootTypes(objs, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(rootTypes))
+	for i, t := range rootTypes {
+		names[i] = t.String()
+	}
+	// the imports are shared so they can be merged into one block
+	imports := newImportNames()
+	f := NewFile(pkg, opts...)
+	complete, err := f.addGenerated(names, func(i int) ([]byte, []byte, error) {
+		return generateCopy(fromReflect(rootTypes[i]), opt, imports)
+	})
+	if !complete {
+		return nil, err
+	}
+	src, ferr := f.Bytes()
+	if ferr != nil {
+		return nil, ferr
+	}
+	return src, err
+}
+
+// fileRootTypes returns the types to generate a file for `objs` for, without
+// duplicates and ordered by type name.
+// It is an error to pass both a value and a pointer of the same type, since
+// the methods generated for them would have the same name.
+func fileRootTypes(objs []interface{}, opt options) ([]reflect.Type, error) {
+	var rootTypes []reflect.Type
+	// seen maps the types pointed to by the root types to the root types
+	seen := make(map[reflect.Type]reflect.Type)
+	for _, o := range objs {
+		t := reflect.TypeOf(o)
+		if err := checkRootType(t); err != nil {
+			return nil, err
+		}
+		if opt.pointerReceiver && t.Kind() != reflect.Ptr {
+			t = reflect.PtrTo(t)
+		}
+		other, ok := seen[baseType(t)]
+		switch {
+		case !ok:
+			seen[baseType(t)] = t
+			rootTypes = append(rootTypes, t)
+		case other != t:
+			return nil, fmt.Errorf("cannot generate methods for both %s and %s, use one of them or the PointerReceiver option", other, t)
+		}
+	}
+	sort.Slice(rootTypes, func(i, j int) bool {
+		a, b := baseType(rootTypes[i]), baseType(rootTypes[j])
+		if a.Name() != b.Name() {
+			return a.Name() < b.Name()
+		}
+		// types with the same name from different packages
+		return a.String() < b.String()
+	})
+	return rootTypes, nil
+}
+
+// baseType returns the type pointed to by `t` if it is an unnamed pointer, or
+// `t` itself.
+func baseType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr && t.Name() == "" {
+		return t.Elem()
+	}
+	return t
+}
+
+// GenerateFile generates the copy functions for each of the types named in
+// `typeNames`, or for the types marked with a `// +deepcopy` comment if there
+// are none, into a single file in the package.
+// The functions are ordered by type name.
+//
+// The errors for the fields which cannot be copied are reported together for
+// all of the types. With the `BestEffort` option the file is returned along
+// with them, as long as some code could be generated for every type.
+func (p *Package) GenerateFile(typeNames []string, opts ...Option) (*File, error) {
+	typeNames, err := p.fileTypeNames(typeNames)
+	if err != nil {
+		return nil, err
+	}
+
+	opt := newOptions(opts)
+	if err := p.checkMissing(typeNames, opt); err != nil {
+		return nil, err
+	}
+	f := NewFile(p.Name(), opts...)
+	complete, err := f.addGenerated(typeNames, func(i int) ([]byte, []byte, error) {
+		return p.generate(typeNames[i], opt)
+	})
+	if !complete {
+		return nil, err
+	}
+	return f, err
+}
+
+// fileTypeNames returns the sorted names of the types to generate a file
+// for: `typeNames`, or the marked types if there are none.
+func (p *Package) fileTypeNames(typeNames []string) ([]string, error) {
+	if len(typeNames) == 0 {
+		typeNames = p.MarkedTypes()
+		if len(typeNames) == 0 {
+			return nil, fmt.Errorf("no types given or marked with // +deepcopy in %s", p.Path())
+		}
+	}
+	typeNames = append([]string(nil), typeNames...)
+	sort.Strings(typeNames)
+	return typeNames, nil
+}
+
+// addGenerated adds the code returned by `generate` for each of the types in
+// `names` to the file.
+// The errors for the fields which cannot be copied are collected for all of
+// the types and returned together. `complete` is false if there is a type
+// which no code could be generated for.
+func (f *File) addGenerated(names []string, generate func(i int) (importsBuf, copyFnBuf []byte, err error)) (complete bool, err error) {
+	var typeErrs Errors
+	complete = true
+	for i, name := range names {
+		importsBuf, copyFnBuf, err := generate(i)
+		if err != nil {
+			var errs Errors
+			var typeErr *TypeError
+			switch {
+			case errors.As(err, &errs):
+				typeErrs = append(typeErrs, errs...)
+			case errors.As(err, &typeErr):
+				// the path of the error already starts with the type name
+				typeErrs = append(typeErrs, typeErr)
+			default:
+				return false, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		if copyFnBuf == nil {
+			complete = false
+			continue
+		}
+		if err := f.Add(importsBuf, copyFnBuf); err != nil {
+			return false, err
+		}
+	}
+	return complete, errorList(typeErrs)
+}
@@ -0,0 +1,162 @@
+package deepcopy
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestFileAdd(t *testing.T) {
+	f := NewFile("p")
+	for _, block := range []string{
+		"import (\nfoo \"example.com/foo\"\n\n)\n",
+		"import (\nfoo \"example.com/foo\"\nbar2 \"example.com/bar\"\n\n)\n",
+		"import (\n\"example.com/baz\"\n)\n",
+		"",
+	} {
+		if err := f.Add([]byte(block), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(f.imports) != 3 || f.imports["example.com/foo"] != "foo" || f.imports["example.com/bar"] != "bar2" || f.imports["example.com/baz"] != "baz" {
+		t.Fatalf("unexpected imports: %v", f.imports)
+	}
+
+	if err := f.Add([]byte("import (\nfoo \"example.org/foo\"\n)\n"), nil); err == nil {
+		t.Fatal("expected an error for an import name used twice")
+	}
+}
+
+var fileX = []byte(`// Code generated by deepcopy. DO NOT EDIT.
+
+//go:build linux && !race
+
+package deepcopy
+
+import (
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
+)
+
+func (o simpleStruct) Copy() simpleStruct {
+	oCopy := o
+
+	return oCopy
+}
+
+func (o structWithImports) Copy() structWithImports {
+	oCopy := o
+	if o.A != nil {
+		var oCopy_A fixtures.Foo
+		oCopy_A = *o.A
+		oCopy.A = &oCopy_A
+		if o.A.B != nil {
+			oCopy.A.B = make(map[string]string, len(o.A.B))
+			for i0, v0 := range o.A.B {
+				oCopy.A.B[i0] = v0
+			}
+
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+func TestFileBytes(t *testing.T) {
+	f := NewFile("deepcopy", BuildTags("linux && !race"))
+	for _, o := range []interface{}{simpleStruct{}, structWithImports{}} {
+		imports, copyFunc, err := Generate("o", o, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Add(imports, copyFunc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	actual, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, fileX) {
+		t.Fatalf("expected: \n%s\n\ngot: \n%s\n\n", fileX, actual)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if n, err := f.WriteTo(buf); err != nil || n != int64(len(fileX)) || !bytes.Equal(buf.Bytes(), fileX) {
+		t.Fatalf("unexpected result writing the file: %d, %v\n%s", n, err, buf)
+	}
+
+	if _, err := NewFile("deepcopy", BuildTags("linux &&")).Bytes(); err == nil {
+		t.Fatal("expected an error for invalid build tags")
+	}
+}
+
+func TestPackageGenerateFile(t *testing.T) {
+	pkg := loadFixtures(t)
+	f, err := pkg.GenerateFile([]string{"simpleStruct", "structWithImports"}, BuildTags("linux && !race"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, fileX) {
+		t.Fatalf("expected: \n%s\n\ngot: \n%s\n\n", fileX, actual)
+	}
+
+	f, err = pkg.GenerateFile([]string{"simpleStruct", "structWithChannel"})
+	if f != nil || !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+	f, err = pkg.GenerateFile([]string{"simpleStruct", "structWithChannel"}, BestEffort())
+	if f == nil || !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected a file and '%v', got: %v", ErrUnsupportedType, err)
+	}
+}
+
+func TestGenerateFile(t *testing.T) {
+	actual, err := GenerateFileWithOptions("deepcopy", []interface{}{structWithImports{}, simpleStruct{}, structWithImports{}}, BuildTags("linux && !race"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, fileX) {
+		t.Fatalf("expected: \n%s\n\ngot: \n%s\n\n", fileX, actual)
+	}
+
+	// the output does not depend on the order of the objects
+	objs := []interface{}{structWithStdlibTypes{}, structWithImports{}, &structWithLocks{}, linkedList{}}
+	expected, err := GenerateFile("deepcopy", objs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(expected, []byte("import (")) != 1 {
+		t.Fatalf("expected a single import block:\n%s", expected)
+	}
+	for i := 0; i < 5; i++ {
+		objs[0], objs[len(objs)-1-i%len(objs)] = objs[len(objs)-1-i%len(objs)], objs[0]
+		actual, err := GenerateFile("deepcopy", objs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("expected: \n%s\n\ngot: \n%s\n\n", expected, actual)
+		}
+	}
+
+	// the methods for a type and a pointer to it would have the same name
+	if _, err := GenerateFile("deepcopy", simpleStruct{}, &simpleStruct{}); err == nil {
+		t.Fatal("expected an error generating methods for both simpleStruct and *simpleStruct")
+	}
+	if _, err := GenerateFileWithOptions("deepcopy", []interface{}{simpleStruct{}, &simpleStruct{}}, PointerReceiver()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = GenerateFile("deepcopy", worker{}, pathRoot{})
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 3 || errs[0].Path != "pathRoot.Items[].Owner.done" || errs[1].Path != "worker.done" {
+		t.Fatalf("unexpected errors: %v", err)
+	}
+}
@@ -54,3 +54,17 @@ type StrSlice []string
 type Unsettable struct {
 	a string
 }
+
+// Handle is a fixture which can only be copied with CopyHandle.
+type Handle struct {
+	id *int
+}
+
+// CopyHandle makes a copy of a Handle.
+func CopyHandle(h Handle) Handle {
+	if h.id != nil {
+		id := *h.id
+		h.id = &id
+	}
+	return h
+}
@@ -1,6 +1,16 @@
 package deepcopy
 
-import "github.com/cpuguy83/go-generate/deepcopy/fixtures"
+import (
+	"math/big"
+	"net"
+	"net/url"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
+)
 
 type stringType string
 
@@ -123,6 +133,8 @@ func(o mapOfSlices) Copy() mapOfSlices {
 				oCopy[i0][i1] = v1
 			}
 
+		} else {
+			oCopy[i0] = nil
 		}
 
 	}
@@ -143,8 +155,154 @@ func(o mapOfMaps) Copy() mapOfMaps {
 				oCopy[i0][i1] = v1
 			}
 
+		} else {
+			oCopy[i0] = nil
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+// mapOfArrays holds arrays in a map, which can't be assigned to element by
+// element.
+type mapOfArrays map[string][2]*simpleStruct
+
+// structWithArrays holds arrays of a struct from the same package in other
+// types.
+type structWithArrays struct {
+	A [][2]simpleStruct
+	B *[2]simpleStruct
+	C map[string][2]simpleStruct
+}
+
+var structWithArraysX = []byte(`
+func (o structWithArrays) Copy() structWithArrays {
+	oCopy := o
+	if o.A != nil {
+		oCopy.A = make([][2]simpleStruct, len(o.A))
+		for i0, v0 := range o.A {
+			for i1, v1 := range v0 {
+				oCopy.A[i0][i1] = v1
+			}
+		}
+
+	}
+
+	if o.B != nil {
+		var oCopy_B [2]simpleStruct
+		oCopy_B = *o.B
+		oCopy.B = &oCopy_B
+		for i0, v0 := range *o.B {
+			oCopy_B[i0] = v0
+		}
+	}
+
+	if o.C != nil {
+		oCopy.C = make(map[string][2]simpleStruct, len(o.C))
+		for i0, v0 := range o.C {
+			oCopy_C0 := v0
+			for i1, v1 := range v0 {
+				oCopy_C0[i1] = v1
+			}
+			oCopy.C[i0] = oCopy_C0
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+// structWithImportedArrays holds arrays of a struct from another package in
+// other types.
+type structWithImportedArrays struct {
+	A map[string][2]fixtures.Foo
+	B [][2]fixtures.Foo
+	C *[2]fixtures.Foo
+}
+
+var structWithImportedArraysX = []byte(`
+import (
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
+)
+
+func (o structWithImportedArrays) Copy() structWithImportedArrays {
+	oCopy := o
+	if o.A != nil {
+		oCopy.A = make(map[string][2]fixtures.Foo, len(o.A))
+		for i0, v0 := range o.A {
+			oCopy_A0 := v0
+			for i1, v1 := range v0 {
+				oCopy_A0[i1] = v1
+				if v1.B != nil {
+					oCopy_A0[i1].B = make(map[string]string, len(v1.B))
+					for i2, v2 := range v1.B {
+						oCopy_A0[i1].B[i2] = v2
+					}
+
+				}
+
+			}
+			oCopy.A[i0] = oCopy_A0
+		}
+
+	}
+
+	if o.B != nil {
+		oCopy.B = make([][2]fixtures.Foo, len(o.B))
+		for i0, v0 := range o.B {
+			for i1, v1 := range v0 {
+				oCopy.B[i0][i1] = v1
+				if v1.B != nil {
+					oCopy.B[i0][i1].B = make(map[string]string, len(v1.B))
+					for i2, v2 := range v1.B {
+						oCopy.B[i0][i1].B[i2] = v2
+					}
+
+				}
+
+			}
+		}
+
+	}
+
+	if o.C != nil {
+		var oCopy_C [2]fixtures.Foo
+		oCopy_C = *o.C
+		oCopy.C = &oCopy_C
+		for i0, v0 := range *o.C {
+			oCopy_C[i0] = v0
+			if v0.B != nil {
+				oCopy_C[i0].B = make(map[string]string, len(v0.B))
+				for i1, v1 := range v0.B {
+					oCopy_C[i0].B[i1] = v1
+				}
+
+			}
+
 		}
+	}
+
+	return oCopy
+}
+`)
 
+var mapOfArraysX = []byte(`
+func(o mapOfArrays) Copy() mapOfArrays {
+	oCopy := make(mapOfArrays, len(o))
+	for i0, v0 := range o {
+		oCopy0 := v0
+		for i1, v1 := range v0 {
+			if v1 != nil {
+				var oCopy01 simpleStruct
+				oCopy01 = *v1
+				oCopy0[i1] = &oCopy01
+			}
+
+		}
+		oCopy[i0] = oCopy0
 	}
 
 	return oCopy
@@ -239,6 +397,8 @@ func (o complexStruct) Copy() complexStruct {
 				var oCopy_D0 simpleStruct
 				oCopy_D0 = *v0
 				oCopy.D[i0] = &oCopy_D0
+			} else {
+				oCopy.D[i0] = nil
 			}
 
 		}
@@ -301,6 +461,8 @@ func (o complexStruct) Copy() complexStruct {
 						oCopy.H.X[i0].A = &oCopy_H0_X01_A
 					}
 
+				} else {
+					oCopy.H.X[i0] = nil
 				}
 
 			}
@@ -310,13 +472,14 @@ func (o complexStruct) Copy() complexStruct {
 		if o.H.Y != nil {
 			oCopy.H.Y = make(map[string]struct{ A *string }, len(o.H.Y))
 			for i0, v0 := range o.H.Y {
-				oCopy.H.Y[i0] = v0
+				oCopy_H0_Y0 := v0
 				if v0.A != nil {
 					var oCopy_H0_Y0_A string
 					oCopy_H0_Y0_A = *v0.A
-					oCopy.H.Y[i0].A = &oCopy_H0_Y0_A
+					oCopy_H0_Y0.A = &oCopy_H0_Y0_A
 				}
 
+				oCopy.H.Y[i0] = oCopy_H0_Y0
 			}
 
 		}
@@ -328,6 +491,8 @@ func (o complexStruct) Copy() complexStruct {
 					var oCopy_H0_Z0 string
 					oCopy_H0_Z0 = *v0
 					oCopy.H.Z[i0] = &oCopy_H0_Z0
+				} else {
+					oCopy.H.Z[i0] = nil
 				}
 
 			}
@@ -346,13 +511,13 @@ type structWithImports struct {
 
 var structWithImportsX = []byte(`
 import (
-	github_com_cpuguy83_go_generate_deepcopy_fixtures "github.com/cpuguy83/go-generate/deepcopy/fixtures"
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
 )
 
 func(o structWithImports) Copy() structWithImports {
 	oCopy := o
 	if o.A != nil {
-		var oCopy_A github_com_cpuguy83_go_generate_deepcopy_fixtures.Foo
+		var oCopy_A fixtures.Foo
 		oCopy_A = *o.A
 		oCopy.A = &oCopy_A
 		if o.A.B != nil {
@@ -411,23 +576,24 @@ type structWithImportNeededMap struct {
 
 var structWithImportNeededMapX = []byte(`
 import (
-	github_com_cpuguy83_go_generate_deepcopy_fixtures "github.com/cpuguy83/go-generate/deepcopy/fixtures"
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
 )
 
 func (o structWithImportNeededMap) Copy() structWithImportNeededMap {
 	oCopy := o
 	if o.A != nil {
-		oCopy.A = make(map[string]github_com_cpuguy83_go_generate_deepcopy_fixtures.Foo, len(o.A))
+		oCopy.A = make(map[string]fixtures.Foo, len(o.A))
 		for i0, v0 := range o.A {
-			oCopy.A[i0] = v0
+			oCopy_A0 := v0
 			if v0.B != nil {
-				oCopy.A[i0].B = make(map[string]string, len(v0.B))
+				oCopy_A0.B = make(map[string]string, len(v0.B))
 				for i1, v1 := range v0.B {
-					oCopy.A[i0].B[i1] = v1
+					oCopy_A0.B[i1] = v1
 				}
 
 			}
 
+			oCopy.A[i0] = oCopy_A0
 		}
 
 	}
@@ -441,13 +607,13 @@ type structWithImportNeededSlice struct {
 
 var structWithImportNeededSliceX = []byte(`
 import (
-	github_com_cpuguy83_go_generate_deepcopy_fixtures "github.com/cpuguy83/go-generate/deepcopy/fixtures"
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
 )
 
 func (o structWithImportNeededSlice) Copy() structWithImportNeededSlice {
 	oCopy := o
 	if o.A != nil {
-		oCopy.A = make([]github_com_cpuguy83_go_generate_deepcopy_fixtures.Foo, len(o.A))
+		oCopy.A = make([]fixtures.Foo, len(o.A))
 		for i0, v0 := range o.A {
 			oCopy.A[i0] = v0
 			if v0.B != nil {
@@ -472,13 +638,13 @@ type structWithImportedCustomSliceType struct {
 
 var structWithImportedCustomSliceTypeX = []byte(`
 import (
-	github_com_cpuguy83_go_generate_deepcopy_fixtures "github.com/cpuguy83/go-generate/deepcopy/fixtures"
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
 )
 
 func (o structWithImportedCustomSliceType) Copy() structWithImportedCustomSliceType {
 	oCopy := o
 	if o.A != nil {
-		oCopy.A = make(github_com_cpuguy83_go_generate_deepcopy_fixtures.StrSlice, len(o.A))
+		oCopy.A = make(fixtures.StrSlice, len(o.A))
 		for i0, v0 := range o.A {
 			oCopy.A[i0] = v0
 		}
@@ -498,13 +664,13 @@ type structWithImportsAndSimpleFields struct {
 
 var structWithImportsAndSimpleFieldsX = []byte(`
 import(
-	github_com_cpuguy83_go_generate_deepcopy_fixtures "github.com/cpuguy83/go-generate/deepcopy/fixtures"
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
 )
 
 func (o structWithImportsAndSimpleFields) Copy() structWithImportsAndSimpleFields {
 	oCopy := o
 	if o.A != nil {
-		var oCopy_A github_com_cpuguy83_go_generate_deepcopy_fixtures.Quux
+		var oCopy_A fixtures.Quux
 		oCopy_A = *o.A
 		oCopy.A = &oCopy_A
 	}
@@ -519,13 +685,13 @@ type structWithImportsAndUnsettableFields struct {
 
 var structWithImportsAndUnsettableFieldsX = []byte(`
 import (
-	github_com_cpuguy83_go_generate_deepcopy_fixtures "github.com/cpuguy83/go-generate/deepcopy/fixtures"
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
 )
 
 func (o structWithImportsAndUnsettableFields) Copy() structWithImportsAndUnsettableFields {
 	oCopy := o
 	if o.A != nil {
-		var oCopy_A github_com_cpuguy83_go_generate_deepcopy_fixtures.Banana
+		var oCopy_A fixtures.Banana
 		oCopy_A = *o.A
 		oCopy.A = &oCopy_A
 	}
@@ -541,7 +707,9 @@ type structPtrWithCopyMethod struct {
 var structPtrWithCopyMethodX = []byte(`
 func(o structPtrWithCopyMethod) Copy() structPtrWithCopyMethod {
 	oCopy := o
-	oCopy.A = o.A.Copy()
+	if o.A != nil {
+		oCopy.A = o.A.Copy()
+	}
 
 	return oCopy
 }
@@ -600,3 +768,1470 @@ func (o structWithSkip) Copy() structWithSkip {
 	return oCopy
 }
 `)
+
+// +deepcopy
+type structWithSkipMarker struct {
+	// +deepcopy:skip
+	A map[string]string
+	B []string
+}
+
+var structWithSkipMarkerX = []byte(`
+func (o structWithSkipMarker) Copy() structWithSkipMarker {
+	oCopy := o
+	if o.B != nil {
+		oCopy.B = make([]string, len(o.B))
+		for i0, v0 := range o.B {
+			oCopy.B[i0] = v0
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+type structWithTags struct {
+	A map[string]string `deepcopy:"shallow"`
+	B []string          `deepcopy:"zero"`
+	C simpleStruct      `deepcopy:"zero"`
+	D *simpleStruct     `deepcopy:"nil"`
+	E map[string][]int  `deepcopy:"func=cloneInts"`
+	F int               `deepcopy:"zero"`
+	G []string
+}
+
+func cloneInts(m map[string][]int) map[string][]int {
+	return m
+}
+
+var structWithTagsX = []byte(`
+func (o structWithTags) Copy() structWithTags {
+	oCopy := o
+	oCopy.B = nil
+	oCopy.C = simpleStruct{}
+	oCopy.D = nil
+	oCopy.E = cloneInts(o.E)
+	oCopy.F = 0
+	if o.G != nil {
+		oCopy.G = make([]string, len(o.G))
+		for i0, v0 := range o.G {
+			oCopy.G[i0] = v0
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+// structWithZeroTagsOnImports leaves values of types from another package
+// as the zero value.
+type structWithZeroTagsOnImports struct {
+	A fixtures.Foo       `deepcopy:"zero"`
+	B [2]fixtures.Foo    `deepcopy:"zero"`
+	C [2][]*fixtures.Foo `deepcopy:"zero"`
+}
+
+var structWithZeroTagsOnImportsX = []byte(`
+import (
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
+)
+
+func (o structWithZeroTagsOnImports) Copy() structWithZeroTagsOnImports {
+	oCopy := o
+	oCopy.A = fixtures.Foo{}
+	oCopy.B = [2]fixtures.Foo{}
+	oCopy.C = [2][]*fixtures.Foo{}
+
+	return oCopy
+}
+`)
+
+type structWithTagMarkers struct {
+	// +deepcopy:zero
+	A map[string]string
+	// +deepcopy:func=cloneInts
+	B map[string][]int
+}
+
+var structWithTagMarkersX = []byte(`
+func (o structWithTagMarkers) Copy() structWithTagMarkers {
+	oCopy := o
+	oCopy.A = nil
+	oCopy.B = cloneInts(o.B)
+
+	return oCopy
+}
+`)
+
+type structWithUnknownTag struct {
+	A []string `deepcopy:"deep"`
+}
+
+type structWithNilTagOnValue struct {
+	A int `deepcopy:"nil"`
+}
+
+// structWithPolicyTagOnValue has a channel policy on a field which holds no
+// channels.
+type structWithPolicyTagOnValue struct {
+	A []string `deepcopy:"fresh"`
+}
+
+// registered is only copied with the copiers registered by
+// TestRegisterCopier.
+type registered struct {
+	m map[string]int
+}
+
+func copyRegistered(r *registered) *registered {
+	return r
+}
+
+// registeredHolder can be copied at runtime until a copier is registered for
+// *registered.
+type registeredHolder struct {
+	E *registered
+}
+
+type structWithRegisteredCopiers struct {
+	A fixtures.Handle
+	B *fixtures.Handle
+	C []fixtures.Handle
+	D map[string]*registered
+	E *registered
+}
+
+var structWithRegisteredCopiersX = []byte(`
+import (
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
+)
+
+func (o structWithRegisteredCopiers) Copy() structWithRegisteredCopiers {
+	oCopy := o
+	oCopy.A = fixtures.CopyHandle(o.A)
+	if o.B != nil {
+		var oCopy_B fixtures.Handle
+		oCopy_B = fixtures.CopyHandle(*o.B)
+		oCopy.B = &oCopy_B
+	}
+
+	if o.C != nil {
+		oCopy.C = make([]fixtures.Handle, len(o.C))
+		for i0, v0 := range o.C {
+			oCopy.C[i0] = fixtures.CopyHandle(v0)
+		}
+
+	}
+
+	if o.D != nil {
+		oCopy.D = make(map[string]*registered, len(o.D))
+		for i0, v0 := range o.D {
+			oCopy.D[i0] = copyRegistered(v0)
+		}
+
+	}
+
+	oCopy.E = copyRegistered(o.E)
+
+	return oCopy
+}
+`)
+
+type structWithStdlibTypes struct {
+	T   time.Time
+	TP  *time.Time
+	L   *time.Location
+	I   *big.Int
+	F   *big.Float
+	U   *url.URL
+	IP  net.IP
+	IPs []net.IP
+	Re  *regexp.Regexp
+	M   map[string]*big.Rat
+}
+
+var structWithStdlibTypesX = []byte(`
+import (
+	"bytes"
+	"math/big"
+	"net"
+	"time"
+)
+
+func (o structWithStdlibTypes) Copy() structWithStdlibTypes {
+	oCopy := o
+	if o.TP != nil {
+		var oCopy_TP time.Time
+		oCopy_TP = *o.TP
+		oCopy.TP = &oCopy_TP
+	}
+
+	if o.I != nil {
+		oCopy.I = new(big.Int).Set(o.I)
+	}
+	if o.F != nil {
+		oCopy.F = new(big.Float).Copy(o.F)
+	}
+	if o.U != nil {
+		oCopy_U := *o.U
+		oCopy.U = &oCopy_U
+	}
+	oCopy.IP = net.IP(bytes.Clone(o.IP))
+	if o.IPs != nil {
+		oCopy.IPs = make([]net.IP, len(o.IPs))
+		for i0, v0 := range o.IPs {
+			oCopy.IPs[i0] = net.IP(bytes.Clone(v0))
+		}
+
+	}
+
+	if o.M != nil {
+		oCopy.M = make(map[string]*big.Rat, len(o.M))
+		for i0, v0 := range o.M {
+			if v0 != nil {
+				oCopy.M[i0] = new(big.Rat).Set(v0)
+			} else {
+				oCopy.M[i0] = nil
+			}
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+type lockedInner struct {
+	mu sync.Mutex
+	M  map[string]int
+}
+
+type structWithLocks struct {
+	mu    sync.RWMutex `deepcopy:"guard"`
+	wg    sync.WaitGroup
+	hits  atomic.Int64
+	val   atomic.Value
+	Name  string
+	Tags  []string
+	Inner lockedInner
+	Ptr   *lockedInner
+	Once  *sync.Once
+}
+
+var structWithLocksX = []byte(`
+import (
+	"sync"
+)
+
+func (o *structWithLocks) Copy() *structWithLocks {
+	var oCopy structWithLocks
+	o.mu.RLock()
+	oCopy.Name = o.Name
+	oCopy.Tags = o.Tags
+	oCopy.Ptr = o.Ptr
+	oCopy.Once = o.Once
+	oCopy.hits.Store(o.hits.Load())
+	if v := o.val.Load(); v != nil {
+		oCopy.val.Store(v)
+	}
+	if o.Tags != nil {
+		oCopy.Tags = make([]string, len(o.Tags))
+		for i0, v0 := range o.Tags {
+			oCopy.Tags[i0] = v0
+		}
+
+	}
+
+	oCopy.Inner.M = o.Inner.M
+	if o.Inner.M != nil {
+		oCopy.Inner.M = make(map[string]int, len(o.Inner.M))
+		for i0, v0 := range o.Inner.M {
+			oCopy.Inner.M[i0] = v0
+		}
+
+	}
+
+	if o.Ptr != nil {
+		var oCopy0_Ptr lockedInner
+		oCopy.Ptr = &oCopy0_Ptr
+		oCopy.Ptr.M = o.Ptr.M
+		if o.Ptr.M != nil {
+			oCopy.Ptr.M = make(map[string]int, len(o.Ptr.M))
+			for i0, v0 := range o.Ptr.M {
+				oCopy.Ptr.M[i0] = v0
+			}
+
+		}
+
+	}
+
+	if o.Once != nil {
+		oCopy.Once = new(sync.Once)
+	}
+	o.mu.RUnlock()
+
+	return &oCopy
+}
+`)
+
+// kubeLocked holds locks, which DeepCopyInto must not copy into `out`.
+type kubeLocked struct {
+	mu     sync.Mutex
+	hits   atomic.Int64
+	Inner  lockedInner
+	Meta   kubeMeta
+	Labels map[string]string
+}
+
+// kubeMeta is assigned along with the other fields of kubeLocked.
+type kubeMeta struct {
+	Annotations map[string]string
+}
+
+var kubeLockedX = []byte(`
+func (in *kubeLocked) DeepCopyInto(out *kubeLocked) {
+	var inCopy kubeLocked
+	inCopy.Meta = in.Meta
+	inCopy.Labels = in.Labels
+	inCopy.hits.Store(in.hits.Load())
+	inCopy.Inner.M = in.Inner.M
+	if in.Inner.M != nil {
+		inCopy.Inner.M = make(map[string]int, len(in.Inner.M))
+		for i0, v0 := range in.Inner.M {
+			inCopy.Inner.M[i0] = v0
+		}
+
+	}
+
+	if in.Meta.Annotations != nil {
+		inCopy.Meta.Annotations = make(map[string]string, len(in.Meta.Annotations))
+		for i0, v0 := range in.Meta.Annotations {
+			inCopy.Meta.Annotations[i0] = v0
+		}
+
+	}
+
+	if in.Labels != nil {
+		inCopy.Labels = make(map[string]string, len(in.Labels))
+		for i0, v0 := range in.Labels {
+			inCopy.Labels[i0] = v0
+		}
+
+	}
+
+	out.hits.Store(inCopy.hits.Load())
+	out.Inner.M = inCopy.Inner.M
+	out.Meta = inCopy.Meta
+	out.Labels = inCopy.Labels
+}
+
+func (in *kubeLocked) DeepCopy() *kubeLocked {
+	if in == nil {
+		return nil
+	}
+	out := new(kubeLocked)
+	in.DeepCopyInto(out)
+	return out
+}
+`)
+
+var kubeLockedAliasingX = []byte(`
+import (
+	"reflect"
+)
+func (in *kubeLocked) DeepCopyInto(out *kubeLocked) {
+	inCopy := deepCopy_kubeLocked(in, make(map[interface{}]interface{}))
+	out.hits.Store(inCopy.hits.Load())
+	out.Inner.M = inCopy.Inner.M
+	out.Meta = inCopy.Meta
+	out.Labels = inCopy.Labels
+}
+
+func deepCopy_kubeLocked(in *kubeLocked, visited map[interface{}]interface{}) *kubeLocked {
+	if v, ok := visited[in].(*kubeLocked); ok {
+		return v
+	}
+	var inCopy kubeLocked
+	visited[in] = &inCopy
+	inCopy.Meta = in.Meta
+	inCopy.Labels = in.Labels
+	inCopy.hits.Store(in.hits.Load())
+	inCopy.Inner.M = in.Inner.M
+	if in.Inner.M != nil {
+		if v, ok := visited[reflect.ValueOf(in.Inner.M).Pointer()].(map[string]int); ok {
+			inCopy.Inner.M = v
+		} else {
+			inCopy.Inner.M = make(map[string]int, len(in.Inner.M))
+			visited[reflect.ValueOf(in.Inner.M).Pointer()] = inCopy.Inner.M
+			for i0, v0 := range in.Inner.M {
+				inCopy.Inner.M[i0] = v0
+			}
+		}
+
+	}
+
+	if in.Meta.Annotations != nil {
+		if v, ok := visited[reflect.ValueOf(in.Meta.Annotations).Pointer()].(map[string]string); ok {
+			inCopy.Meta.Annotations = v
+		} else {
+			inCopy.Meta.Annotations = make(map[string]string, len(in.Meta.Annotations))
+			visited[reflect.ValueOf(in.Meta.Annotations).Pointer()] = inCopy.Meta.Annotations
+			for i0, v0 := range in.Meta.Annotations {
+				inCopy.Meta.Annotations[i0] = v0
+			}
+		}
+
+	}
+
+	if in.Labels != nil {
+		if v, ok := visited[reflect.ValueOf(in.Labels).Pointer()].(map[string]string); ok {
+			inCopy.Labels = v
+		} else {
+			inCopy.Labels = make(map[string]string, len(in.Labels))
+			visited[reflect.ValueOf(in.Labels).Pointer()] = inCopy.Labels
+			for i0, v0 := range in.Labels {
+				inCopy.Labels[i0] = v0
+			}
+		}
+
+	}
+
+	return &inCopy
+}
+
+func (in *kubeLocked) DeepCopy() *kubeLocked {
+	if in == nil {
+		return nil
+	}
+	out := new(kubeLocked)
+	in.DeepCopyInto(out)
+	return out
+}
+`)
+
+type structWithLocksInSlice struct {
+	S []lockedInner
+}
+
+type structWithBadGuard struct {
+	wg sync.WaitGroup `deepcopy:"guard"`
+}
+
+// linkedList is recursive through a pointer to itself, the recursion point
+// should call the Copy method of the type.
+type linkedList struct {
+	Value string
+	Next  *linkedList
+}
+
+var linkedListX = []byte(`
+func (o linkedList) Copy() linkedList {
+	oCopy := o
+	if o.Next != nil {
+		var oCopy_Next linkedList
+		oCopy_Next = o.Next.Copy()
+		oCopy.Next = &oCopy_Next
+	}
+
+	return oCopy
+}
+`)
+
+type ptrTree struct {
+	Left, Right *ptrTree
+}
+
+var ptrTreePointerX = []byte(`
+func (o *ptrTree) Copy() *ptrTree {
+	var oCopy ptrTree
+	oCopy = *o
+	if o.Left != nil {
+		oCopy.Left = o.Left.Copy()
+	}
+	if o.Right != nil {
+		oCopy.Right = o.Right.Copy()
+	}
+
+	return &oCopy
+}
+`)
+
+type nestedMap map[string]nestedMap
+
+var nestedMapX = []byte(`
+func (o nestedMap) Copy() nestedMap {
+	oCopy := make(nestedMap, len(o))
+	for i0, v0 := range o {
+		oCopy[i0] = v0.Copy()
+	}
+
+	return oCopy
+}
+`)
+
+// treeNode is recursive but is not the type being generated for, so a helper
+// function needs to be generated for it.
+type tree struct {
+	Root *treeNode
+}
+
+type treeNode struct {
+	Parent   *treeNode
+	Children []*treeNode
+	Labels   map[string]string
+}
+
+// indexedNode is recursive through a slice and a map of pointers, which hold
+// nil pointers where the generated tests stop filling it in.
+type indexedNode struct {
+	Kids  []*indexedNode
+	ByKey map[string]*indexedNode
+}
+
+var treeX = []byte(`
+func (o tree) Copy() tree {
+	oCopy := o
+	if o.Root != nil {
+		var oCopy_Root treeNode
+		oCopy_Root = *o.Root
+		oCopy.Root = &oCopy_Root
+		if o.Root.Parent != nil {
+			var oCopy_Root0_Parent treeNode
+			oCopy_Root0_Parent = deepCopy_tree_treeNode(*o.Root.Parent)
+			oCopy.Root.Parent = &oCopy_Root0_Parent
+		}
+
+		if o.Root.Children != nil {
+			oCopy.Root.Children = make([]*treeNode, len(o.Root.Children))
+			for i0, v0 := range o.Root.Children {
+				if v0 != nil {
+					var oCopy_Root0_Children0 treeNode
+					oCopy_Root0_Children0 = deepCopy_tree_treeNode(*v0)
+					oCopy.Root.Children[i0] = &oCopy_Root0_Children0
+				}
+
+			}
+
+		}
+
+		if o.Root.Labels != nil {
+			oCopy.Root.Labels = make(map[string]string, len(o.Root.Labels))
+			for i0, v0 := range o.Root.Labels {
+				oCopy.Root.Labels[i0] = v0
+			}
+
+		}
+
+	}
+
+	return oCopy
+}
+
+func deepCopy_tree_treeNode(o treeNode) treeNode {
+	oCopy := o
+	if o.Parent != nil {
+		var oCopy_Parent treeNode
+		oCopy_Parent = deepCopy_tree_treeNode(*o.Parent)
+		oCopy.Parent = &oCopy_Parent
+	}
+
+	if o.Children != nil {
+		oCopy.Children = make([]*treeNode, len(o.Children))
+		for i0, v0 := range o.Children {
+			if v0 != nil {
+				var oCopy_Children0 treeNode
+				oCopy_Children0 = deepCopy_tree_treeNode(*v0)
+				oCopy.Children[i0] = &oCopy_Children0
+			}
+
+		}
+
+	}
+
+	if o.Labels != nil {
+		oCopy.Labels = make(map[string]string, len(o.Labels))
+		for i0, v0 := range o.Labels {
+			oCopy.Labels[i0] = v0
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+type sharedRefs struct {
+	A, B []string
+	M    map[string]*simpleStruct
+}
+
+var linkedListAliasingX = []byte(`
+func (o linkedList) Copy() linkedList {
+	return deepCopy_linkedList(o, make(map[interface{}]interface{}))
+}
+
+func deepCopy_linkedList(o linkedList, visited map[interface{}]interface{}) linkedList {
+	oCopy := o
+	if o.Next != nil {
+		if v, ok := visited[o.Next].(*linkedList); ok {
+			oCopy.Next = v
+		} else {
+			var oCopy_Next linkedList
+			oCopy.Next = &oCopy_Next
+			visited[o.Next] = &oCopy_Next
+			oCopy_Next = deepCopy_linkedList(*o.Next, visited)
+		}
+	}
+
+	return oCopy
+}
+`)
+
+var nestedMapAliasingX = []byte(`
+import (
+	"reflect"
+)
+
+func (o nestedMap) Copy() nestedMap {
+	return deepCopy_nestedMap(o, make(map[interface{}]interface{}))
+}
+
+func deepCopy_nestedMap(o nestedMap, visited map[interface{}]interface{}) nestedMap {
+	if o == nil {
+		return nil
+	}
+	if v, ok := visited[reflect.ValueOf(o).Pointer()].(nestedMap); ok {
+		return v
+	}
+	oCopy := make(nestedMap, len(o))
+	visited[reflect.ValueOf(o).Pointer()] = oCopy
+	for i0, v0 := range o {
+		oCopy[i0] = deepCopy_nestedMap(v0, visited)
+	}
+
+	return oCopy
+}
+`)
+
+var sharedRefsAliasingX = []byte(`
+import (
+	"reflect"
+)
+
+func (o sharedRefs) Copy() sharedRefs {
+	return deepCopy_sharedRefs(o, make(map[interface{}]interface{}))
+}
+
+func deepCopy_sharedRefs(o sharedRefs, visited map[interface{}]interface{}) sharedRefs {
+	oCopy := o
+	if o.A != nil {
+		if v, ok := visited[[2]uintptr{reflect.ValueOf(o.A).Pointer(), uintptr(len(o.A))}].([]string); ok {
+			oCopy.A = v
+		} else {
+			oCopy.A = make([]string, len(o.A))
+			visited[[2]uintptr{reflect.ValueOf(o.A).Pointer(), uintptr(len(o.A))}] = oCopy.A
+			for i0, v0 := range o.A {
+				oCopy.A[i0] = v0
+			}
+		}
+
+	}
+
+	if o.B != nil {
+		if v, ok := visited[[2]uintptr{reflect.ValueOf(o.B).Pointer(), uintptr(len(o.B))}].([]string); ok {
+			oCopy.B = v
+		} else {
+			oCopy.B = make([]string, len(o.B))
+			visited[[2]uintptr{reflect.ValueOf(o.B).Pointer(), uintptr(len(o.B))}] = oCopy.B
+			for i0, v0 := range o.B {
+				oCopy.B[i0] = v0
+			}
+		}
+
+	}
+
+	if o.M != nil {
+		if v, ok := visited[reflect.ValueOf(o.M).Pointer()].(map[string]*simpleStruct); ok {
+			oCopy.M = v
+		} else {
+			oCopy.M = make(map[string]*simpleStruct, len(o.M))
+			visited[reflect.ValueOf(o.M).Pointer()] = oCopy.M
+			for i0, v0 := range o.M {
+				if v0 != nil {
+					if v, ok := visited[v0].(*simpleStruct); ok {
+						oCopy.M[i0] = v
+					} else {
+						var oCopy_M0 simpleStruct
+						oCopy.M[i0] = &oCopy_M0
+						visited[v0] = &oCopy_M0
+						oCopy_M0 = *v0
+					}
+				} else {
+					oCopy.M[i0] = nil
+				}
+
+			}
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+type shape interface {
+	area() int
+}
+
+type square struct {
+	Side int
+}
+
+func (s square) area() int {
+	return s.Side * s.Side
+}
+
+type polygon struct {
+	Sides []int
+}
+
+func (p *polygon) area() int {
+	return 0
+}
+
+type copierShape interface {
+	shape
+	Copy() copierShape
+}
+
+type structWithInterfaces struct {
+	A shape
+	B copierShape
+	C []shape
+}
+
+type structWithEmptyInterface struct {
+	A interface{}
+}
+
+type shapeHolder struct {
+	S shape
+}
+
+// structWithInterfaceMap holds values of any type in a map, which are shared
+// with the copy by default unless they have a Copy or DeepCopy method.
+type structWithInterfaceMap struct {
+	M map[string]interface{}
+}
+
+var structWithInterfaceMapX = []byte(`
+func (o structWithInterfaceMap) Copy() structWithInterfaceMap {
+	oCopy := o
+	if o.M != nil {
+		oCopy.M = make(map[string]interface{}, len(o.M))
+		for i0, v0 := range o.M {
+			oCopy.M[i0] = v0
+			if v0 != nil {
+				switch v := v0.(type) {
+				case interface{ Copy() interface{} }:
+					oCopy.M[i0] = v.Copy()
+				case interface{ DeepCopy() interface{} }:
+					oCopy.M[i0] = v.DeepCopy()
+				}
+			}
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+type copierShapeHolder struct {
+	S copierShape
+}
+
+var copierShapeHolderX = []byte(`
+func (o copierShapeHolder) Copy() copierShapeHolder {
+	oCopy := o
+	if o.S != nil {
+		oCopy.S = o.S.Copy()
+	}
+
+	return oCopy
+}
+`)
+
+var structWithInterfacesX = []byte(`
+func (o structWithInterfaces) Copy() structWithInterfaces {
+	oCopy := o
+	if o.A != nil {
+		switch v := o.A.(type) {
+		case interface{ Copy() shape }:
+			oCopy.A = v.Copy()
+		case interface{ DeepCopy() shape }:
+			oCopy.A = v.DeepCopy()
+		}
+	}
+	if o.B != nil {
+		oCopy.B = o.B.Copy()
+	}
+	if o.C != nil {
+		oCopy.C = make([]shape, len(o.C))
+		for i0, v0 := range o.C {
+			oCopy.C[i0] = v0
+			if v0 != nil {
+				switch v := v0.(type) {
+				case interface{ Copy() shape }:
+					oCopy.C[i0] = v.Copy()
+				case interface{ DeepCopy() shape }:
+					oCopy.C[i0] = v.DeepCopy()
+				}
+			}
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+var structWithEmptyInterfaceX = []byte(`
+func (o structWithEmptyInterface) Copy() structWithEmptyInterface {
+	oCopy := o
+	if o.A != nil {
+		switch v := o.A.(type) {
+		case interface{ Copy() interface{} }:
+			oCopy.A = v.Copy()
+		case interface{ DeepCopy() interface{} }:
+			oCopy.A = v.DeepCopy()
+		}
+	}
+
+	return oCopy
+}
+`)
+
+var structWithInterfacesSourceX = []byte(`
+func (o structWithInterfaces) Copy() structWithInterfaces {
+	oCopy := o
+	if o.A != nil {
+		switch v := o.A.(type) {
+		case *polygon:
+			if v != nil {
+				oCopy.A = deepCopy_structWithInterfaces_polygonPtr(v)
+			}
+		case square:
+		case *square:
+			if v != nil {
+				oCopy.A = deepCopy_structWithInterfaces_squarePtr(v)
+			}
+		case interface{ Copy() shape }:
+			oCopy.A = v.Copy()
+		case interface{ DeepCopy() shape }:
+			oCopy.A = v.DeepCopy()
+		}
+	}
+	if o.B != nil {
+		oCopy.B = o.B.Copy()
+	}
+	if o.C != nil {
+		oCopy.C = make([]shape, len(o.C))
+		for i0, v0 := range o.C {
+			oCopy.C[i0] = v0
+			if v0 != nil {
+				switch v := v0.(type) {
+				case *polygon:
+					if v != nil {
+						oCopy.C[i0] = deepCopy_structWithInterfaces_polygonPtr(v)
+					}
+				case square:
+				case *square:
+					if v != nil {
+						oCopy.C[i0] = deepCopy_structWithInterfaces_squarePtr(v)
+					}
+				case interface{ Copy() shape }:
+					oCopy.C[i0] = v.Copy()
+				case interface{ DeepCopy() shape }:
+					oCopy.C[i0] = v.DeepCopy()
+				}
+			}
+		}
+
+	}
+
+	return oCopy
+}
+
+func deepCopy_structWithInterfaces_polygonPtr(o *polygon) *polygon {
+	var oCopy polygon
+	oCopy = *o
+	if o.Sides != nil {
+		oCopy.Sides = make([]int, len(o.Sides))
+		for i0, v0 := range o.Sides {
+			oCopy.Sides[i0] = v0
+		}
+
+	}
+
+	return &oCopy
+}
+
+func deepCopy_structWithInterfaces_squarePtr(o *square) *square {
+	var oCopy square
+	oCopy = *o
+
+	return &oCopy
+}
+`)
+
+var shapeHolderNilX = []byte(`
+func (o shapeHolder) Copy() shapeHolder {
+	oCopy := o
+	if o.S != nil {
+		switch v := o.S.(type) {
+		case *polygon:
+			if v != nil {
+				oCopy.S = deepCopy_shapeHolder_polygonPtr(v)
+			}
+		case square:
+		case *square:
+			if v != nil {
+				oCopy.S = deepCopy_shapeHolder_squarePtr(v)
+			}
+		case interface{ Copy() shape }:
+			oCopy.S = v.Copy()
+		case interface{ DeepCopy() shape }:
+			oCopy.S = v.DeepCopy()
+		default:
+			oCopy.S = nil
+		}
+	}
+
+	return oCopy
+}
+
+func deepCopy_shapeHolder_polygonPtr(o *polygon) *polygon {
+	var oCopy polygon
+	oCopy = *o
+	if o.Sides != nil {
+		oCopy.Sides = make([]int, len(o.Sides))
+		for i0, v0 := range o.Sides {
+			oCopy.Sides[i0] = v0
+		}
+
+	}
+
+	return &oCopy
+}
+
+func deepCopy_shapeHolder_squarePtr(o *square) *square {
+	var oCopy square
+	oCopy = *o
+
+	return &oCopy
+}
+`)
+
+var shapeHolderPanicX = []byte(`
+import (
+	"fmt"
+)
+
+func (o shapeHolder) Copy() shapeHolder {
+	oCopy := o
+	if o.S != nil {
+		switch v := o.S.(type) {
+		case *polygon:
+			if v != nil {
+				oCopy.S = deepCopy_shapeHolder_polygonPtr(v)
+			}
+		case square:
+		case *square:
+			if v != nil {
+				oCopy.S = deepCopy_shapeHolder_squarePtr(v)
+			}
+		case interface{ Copy() shape }:
+			oCopy.S = v.Copy()
+		case interface{ DeepCopy() shape }:
+			oCopy.S = v.DeepCopy()
+		default:
+			panic(fmt.Sprintf("cannot make copy of %T in o.S", v))
+		}
+	}
+
+	return oCopy
+}
+
+func deepCopy_shapeHolder_polygonPtr(o *polygon) *polygon {
+	var oCopy polygon
+	oCopy = *o
+	if o.Sides != nil {
+		oCopy.Sides = make([]int, len(o.Sides))
+		for i0, v0 := range o.Sides {
+			oCopy.Sides[i0] = v0
+		}
+
+	}
+
+	return &oCopy
+}
+
+func deepCopy_shapeHolder_squarePtr(o *square) *square {
+	var oCopy square
+	oCopy = *o
+
+	return &oCopy
+}
+`)
+
+type worker struct {
+	done     chan struct{}
+	jobs     chan<- int `deepcopy:"fresh"`
+	results  []chan int `deepcopy:"nil"`
+	errs     *chan error
+	callback func() error
+	hooks    map[string]func()
+}
+
+var workerShareX = []byte(`
+func (o worker) Copy() worker {
+	oCopy := o
+	if o.jobs != nil {
+		oCopy.jobs = make(chan<- int, cap(o.jobs))
+	}
+	if o.results != nil {
+		oCopy.results = make([]chan int, len(o.results))
+		for i0, v0 := range o.results {
+			oCopy.results[i0] = v0
+			oCopy.results[i0] = nil
+		}
+
+	}
+
+	if o.errs != nil {
+		var oCopy_errs chan error
+		oCopy_errs = *o.errs
+		oCopy.errs = &oCopy_errs
+	}
+
+	if o.hooks != nil {
+		oCopy.hooks = make(map[string]func(), len(o.hooks))
+		for i0, v0 := range o.hooks {
+			oCopy.hooks[i0] = v0
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+var workerFreshX = []byte(`
+func (o worker) Copy() worker {
+	oCopy := o
+	if o.done != nil {
+		oCopy.done = make(chan struct{}, cap(o.done))
+	}
+	if o.jobs != nil {
+		oCopy.jobs = make(chan<- int, cap(o.jobs))
+	}
+	if o.results != nil {
+		oCopy.results = make([]chan int, len(o.results))
+		for i0, v0 := range o.results {
+			oCopy.results[i0] = v0
+			oCopy.results[i0] = nil
+		}
+
+	}
+
+	if o.errs != nil {
+		var oCopy_errs chan error
+		oCopy_errs = *o.errs
+		oCopy.errs = &oCopy_errs
+		if (*o.errs) != nil {
+			oCopy_errs = make(chan error, cap((*o.errs)))
+		}
+	}
+
+	oCopy.callback = nil
+	if o.hooks != nil {
+		oCopy.hooks = make(map[string]func(), len(o.hooks))
+		for i0, v0 := range o.hooks {
+			oCopy.hooks[i0] = v0
+			oCopy.hooks[i0] = nil
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+var structWithImportsCloneX = []byte(`import (
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
+)
+func (s *structWithImports) Clone() *structWithImports {
+	var sCopy structWithImports
+	sCopy = *s
+	if s.A != nil {
+		var sCopy0_A fixtures.Foo
+		sCopy0_A = *s.A
+		sCopy.A = &sCopy0_A
+		if s.A.B != nil {
+			sCopy.A.B = make(map[string]string, len(s.A.B))
+			for i0, v0 := range s.A.B {
+				sCopy.A.B[i0] = v0
+			}
+
+		}
+
+	}
+
+	return &sCopy
+}
+`)
+
+type kubeTemplate struct {
+	Labels map[string]string
+}
+
+func (in *kubeTemplate) DeepCopyInto(out *kubeTemplate) {
+	*out = *in
+	if in.Labels != nil {
+		out.Labels = make(map[string]string, len(in.Labels))
+		for k, v := range in.Labels {
+			out.Labels[k] = v
+		}
+	}
+}
+
+type kubeObject struct {
+	Template  kubeTemplate
+	Templates map[string]kubeTemplate
+	Ptr       *kubeTemplate
+	Next      *kubeObject
+	Items     []kubeObject
+}
+
+var kubeObjectX = []byte(`import (
+	"k8s.io/apimachinery/pkg/runtime"
+)
+func (in *kubeObject) DeepCopyInto(out *kubeObject) {
+	var inCopy kubeObject
+	inCopy = *in
+	in.Template.DeepCopyInto(&inCopy.Template)
+	if in.Templates != nil {
+		inCopy.Templates = make(map[string]kubeTemplate, len(in.Templates))
+		for i0, v0 := range in.Templates {
+			var inCopy0_Templates0 kubeTemplate
+			v0.DeepCopyInto(&inCopy0_Templates0)
+			inCopy.Templates[i0] = inCopy0_Templates0
+		}
+
+	}
+
+	if in.Ptr != nil {
+		var inCopy0_Ptr kubeTemplate
+		inCopy.Ptr = &inCopy0_Ptr
+		in.Ptr.DeepCopyInto(&inCopy0_Ptr)
+	}
+
+	if in.Next != nil {
+		inCopy.Next = in.Next.DeepCopy()
+	}
+	if in.Items != nil {
+		inCopy.Items = make([]kubeObject, len(in.Items))
+		for i0, v0 := range in.Items {
+			v0.DeepCopyInto(&inCopy.Items[i0])
+		}
+
+	}
+
+	*out = inCopy
+}
+
+func (in *kubeObject) DeepCopy() *kubeObject {
+	if in == nil {
+		return nil
+	}
+	out := new(kubeObject)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *kubeObject) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+`)
+
+type structWithPtrReceiverCopy struct {
+	A fixtures.Apple
+	B *fixtures.Apricot
+	C map[string]fixtures.Apple
+}
+
+type embedsCopier struct {
+	fixtures.Apricot
+	M map[string]string
+}
+
+type cloner struct {
+	M map[string]string
+}
+
+func (c *cloner) Clone() *cloner {
+	cp := *c
+	if c.M != nil {
+		cp.M = make(map[string]string, len(c.M))
+		for k, v := range c.M {
+			cp.M[k] = v
+		}
+	}
+	return &cp
+}
+
+type structWithCloner struct {
+	A cloner
+	B []*cloner
+}
+
+type badCopier struct{}
+
+func (badCopier) Copy() int {
+	return 0
+}
+
+type structWithBadCopier struct {
+	A badCopier
+}
+
+var structWithPtrReceiverCopyX = []byte(`
+import (
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
+)
+
+func (o structWithPtrReceiverCopy) Copy() structWithPtrReceiverCopy {
+	oCopy := o
+	oCopy.A = *o.A.Copy()
+	if o.B != nil {
+		oCopy_B := o.B.Copy()
+		oCopy.B = &oCopy_B
+	}
+	if o.C != nil {
+		oCopy.C = make(map[string]fixtures.Apple, len(o.C))
+		for i0, v0 := range o.C {
+			oCopy.C[i0] = *v0.Copy()
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+var embedsCopierX = []byte(`
+func (o embedsCopier) Copy() embedsCopier {
+	oCopy := o
+	oCopy.Apricot = o.Apricot.Copy()
+	if o.M != nil {
+		oCopy.M = make(map[string]string, len(o.M))
+		for i0, v0 := range o.M {
+			oCopy.M[i0] = v0
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+var structWithClonerX = []byte(`
+func (o structWithCloner) Copy() structWithCloner {
+	oCopy := o
+	oCopy.A = *o.A.Clone()
+	if o.B != nil {
+		oCopy.B = make([]*cloner, len(o.B))
+		for i0, v0 := range o.B {
+			if v0 != nil {
+				oCopy.B[i0] = v0.Clone()
+			}
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+type pathRoot struct {
+	Items []pathItem
+}
+
+type pathItem struct {
+	Owner *pathOwner
+}
+
+type pathOwner struct {
+	done chan int
+}
+
+type structWithAnonymousStruct struct {
+	A struct {
+		F fixtures.Foo
+	}
+}
+
+var workerBestEffortX = []byte(`func (o worker) Copy() worker {
+	oCopy := o
+	// deepcopy: skipped worker.done: cannot make copy of channel types: unsupported type
+	if o.jobs != nil {
+		oCopy.jobs = make(chan<- int, cap(o.jobs))
+	}
+	if o.results != nil {
+		oCopy.results = make([]chan int, len(o.results))
+		for i0, v0 := range o.results {
+			oCopy.results[i0] = v0
+			oCopy.results[i0] = nil
+		}
+
+	}
+
+	// deepcopy: skipped worker.errs: cannot make copy of channel types: unsupported type
+	if o.hooks != nil {
+		oCopy.hooks = make(map[string]func(), len(o.hooks))
+		for i0, v0 := range o.hooks {
+			oCopy.hooks[i0] = v0
+		}
+
+	}
+
+	return oCopy
+}
+`)
+
+// valueCopier is the same as `Copier`, which can't be used here since the fixtures
+// are loaded from source on their own.
+type valueCopier[T any] interface {
+	Copy() T
+}
+
+type genericSet[T comparable] map[T]struct{}
+
+// genericTree is copied with type parameters, values of `V` are copied with
+// their Copy method and values of `K` by assignment.
+type genericTree[K comparable, V valueCopier[V]] struct {
+	Root   *genericNode[K, V]
+	Keys   genericSet[K]
+	Values []V
+	Zero   V `deepcopy:"zero"`
+	Apples map[string]genericSet[fixtures.Apple]
+}
+
+type genericNode[K comparable, V any] struct {
+	Key         K
+	Value       V
+	Left, Right *genericNode[K, V]
+}
+
+type structWithNilTagOnTypeParam[T any] struct {
+	A T `deepcopy:"nil"`
+}
+
+var genericSetX = []byte(`
+func (o genericSet[T]) Copy() genericSet[T] {
+	oCopy := make(genericSet[T], len(o))
+	for i0, v0 := range o {
+		oCopy[i0] = v0
+	}
+
+	return oCopy
+}
+`)
+
+var genericTreeX = []byte(`
+import (
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
+)
+
+func (o genericTree[K, V]) Copy() genericTree[K, V] {
+	oCopy := o
+	if o.Root != nil {
+		var oCopy_Root genericNode[K, V]
+		oCopy_Root = *o.Root
+		oCopy.Root = &oCopy_Root
+		oCopy.Root.Value = o.Root.Value.Copy()
+		if o.Root.Left != nil {
+			var oCopy_Root0_Left genericNode[K, V]
+			oCopy_Root0_Left = deepCopy_genericTree_genericNode_K_V[K, V](*o.Root.Left)
+			oCopy.Root.Left = &oCopy_Root0_Left
+		}
+
+		if o.Root.Right != nil {
+			var oCopy_Root0_Right genericNode[K, V]
+			oCopy_Root0_Right = deepCopy_genericTree_genericNode_K_V[K, V](*o.Root.Right)
+			oCopy.Root.Right = &oCopy_Root0_Right
+		}
+
+	}
+
+	if o.Keys != nil {
+		oCopy.Keys = make(genericSet[K], len(o.Keys))
+		for i0, v0 := range o.Keys {
+			oCopy.Keys[i0] = v0
+		}
+
+	}
+
+	if o.Values != nil {
+		oCopy.Values = make([]V, len(o.Values))
+		for i0, v0 := range o.Values {
+			oCopy.Values[i0] = v0.Copy()
+		}
+
+	}
+
+	oCopy.Zero = *new(V)
+	if o.Apples != nil {
+		oCopy.Apples = make(map[string]genericSet[fixtures.Apple], len(o.Apples))
+		for i0, v0 := range o.Apples {
+			if v0 != nil {
+				oCopy.Apples[i0] = make(genericSet[fixtures.Apple], len(v0))
+				for i1, v1 := range v0 {
+					oCopy.Apples[i0][i1] = v1
+				}
+
+			} else {
+				oCopy.Apples[i0] = nil
+			}
+
+		}
+
+	}
+
+	return oCopy
+}
+
+func deepCopy_genericTree_genericNode_K_V[K comparable, V valueCopier[V]](o genericNode[K, V]) genericNode[K, V] {
+	oCopy := o
+	oCopy.Value = o.Value.Copy()
+	if o.Left != nil {
+		var oCopy_Left genericNode[K, V]
+		oCopy_Left = deepCopy_genericTree_genericNode_K_V[K, V](*o.Left)
+		oCopy.Left = &oCopy_Left
+	}
+
+	if o.Right != nil {
+		var oCopy_Right genericNode[K, V]
+		oCopy_Right = deepCopy_genericTree_genericNode_K_V[K, V](*o.Right)
+		oCopy.Right = &oCopy_Right
+	}
+
+	return oCopy
+}
+`)
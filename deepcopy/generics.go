@@ -0,0 +1,104 @@
+package deepcopy
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Copier is a constraint for the type parameters of generic types, to have
+// values of the type parameter copied with their `Copy` method:
+//
+//	type Tree[K comparable, V deepcopy.Copier[V]] struct { ... }
+//
+// Any other constraint with a `Copy() T` or `DeepCopy() T` method works the
+// same way. Values of type parameters with constraints which have no such
+// method are copied by assignment.
+type Copier[T any] interface {
+	Copy() T
+}
+
+// instantiate returns the generic type `t` instantiated with its own type
+// parameters, which is the type as it is referred to within its declaration
+// (e.g. `Tree[K, V]`). Other types are returned as is.
+func instantiate(t types.Type) (types.Type, error) {
+	named, ok := t.(*types.Named)
+	if !ok || named.TypeParams().Len() == 0 || named.TypeArgs().Len() > 0 {
+		return t, nil
+	}
+	args := make([]types.Type, named.TypeParams().Len())
+	for i := range args {
+		args[i] = named.TypeParams().At(i)
+	}
+	return types.Instantiate(nil, named, args, false)
+}
+
+// isTypeParam determines if `t` is a type parameter.
+func isTypeParam(t goType) bool {
+	st, ok := t.(sourceType)
+	if !ok {
+		return false
+	}
+	_, ok = st.t.(*types.TypeParam)
+	return ok
+}
+
+// typeParams returns the declaration of the type parameters of the generic
+// type `t`, e.g. `[K comparable, V any]`, and the list of them to instantiate
+// a generic function with, e.g. `[K, V]`.
+// Both are empty if `t` is not generic.
+func (g *generator) typeParams(t goType) (decl, args string) {
+	if t.Kind() == reflect.Ptr && t.Name() == "" {
+		t = t.Elem()
+	}
+	st, ok := t.(sourceType)
+	if !ok {
+		return "", ""
+	}
+	named, ok := st.t.(*types.Named)
+	if !ok || named.TypeArgs().Len() == 0 {
+		return "", ""
+	}
+
+	var params, names []string
+	for i := 0; i < named.TypeArgs().Len(); i++ {
+		tp, ok := named.TypeArgs().At(i).(*types.TypeParam)
+		if !ok {
+			return "", ""
+		}
+		constraint := st.wrap(tp.Constraint())
+		g.addImport(constraint)
+		params = append(params, tp.Obj().Name()+" "+g.getName(constraint))
+		names = append(names, tp.Obj().Name())
+	}
+	return "[" + strings.Join(params, ", ") + "]", "[" + strings.Join(names, ", ") + "]"
+}
+
+// writeTypeParam writes the code to copy the value at `t`, which has the type
+// of a type parameter. It is copied with the `Copy` (or `DeepCopy`) method of
+// its constraint if it has one, or by assignment otherwise.
+func (g *generator) writeTypeParam(buf *bytes.Buffer, t *reflectType, copyStr, copyVal string) {
+	if m, ok := copierMethod(t.goType, t.goType); ok {
+		fmt.Fprintf(buf, "%s = %s.%s()\n", copyStr, copyVal, m)
+		return
+	}
+	if !isKind(t.parent, reflect.Struct, reflect.Ptr) {
+		// values in structs and behind pointers were already assigned
+		fmt.Fprintf(buf, "%s = %s\n", copyStr, copyVal)
+	}
+}
+
+// funcName converts the name of a type into something which can be used in
+// the name of a function, e.g. `node[K, V]` becomes `node_K_V`.
+func funcName(name string) string {
+	name = strings.NewReplacer("[", "_", "]", "", ", ", "_").Replace(name)
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
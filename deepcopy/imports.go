@@ -0,0 +1,223 @@
+package deepcopy
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// importNames assigns the names that packages are imported with in generated
+// code. Packages are imported with their own name unless it is already used
+// by another package, or by something else in the generated code, in which
+// case a number is added to it (e.g. `fixtures2`).
+//
+// The same importNames is used for all of the functions generated for a
+// package loaded from source, so that their imports can be merged into a
+// single file.
+type importNames struct {
+	mu sync.Mutex
+	// byPath holds the name of each import path
+	byPath map[string]string
+	// byName holds the import path of each name
+	byName map[string]string
+	// reserved are the names which can't be used, such as the top level
+	// declarations of the package the code is generated for
+	reserved map[string]bool
+}
+
+func newImportNames(reserved ...string) *importNames {
+	n := &importNames{
+		byPath:   make(map[string]string),
+		byName:   make(map[string]string),
+		reserved: make(map[string]bool, len(reserved)),
+	}
+	for _, name := range reserved {
+		n.reserved[name] = true
+	}
+	return n
+}
+
+// name returns the name to import the package at `importPath` with.
+// `pkgName` is the name of the package, if it is known. `taken` reports names
+// which are used by the code being generated.
+func (n *importNames) name(importPath, pkgName string, taken func(string) bool) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if name, ok := n.byPath[importPath]; ok {
+		return name
+	}
+	if pkgName == "" {
+		pkgName = guessPkgName(importPath)
+	}
+	name := pkgName
+	for i := 2; n.byName[name] != "" || n.reserved[name] || taken(name); i++ {
+		name = pkgName + strconv.Itoa(i)
+	}
+	n.byPath[importPath], n.byName[name] = name, importPath
+	return name
+}
+
+// guessPkgName returns the likely name of the package at `importPath`, for
+// packages which are only known by their path: the last element of the path,
+// skipping major version suffixes (e.g. `/v2`) and dropping `go-` prefixes and
+// `.v2` style suffixes.
+func guessPkgName(importPath string) string {
+	name := path.Base(importPath)
+	if isMajorVersion(name) && path.Dir(importPath) != "." {
+		name = path.Base(path.Dir(importPath))
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return -1
+	}, name)
+	if name == "" || !token.IsIdentifier(name) {
+		return "pkg"
+	}
+	return name
+}
+
+// isMajorVersion determines if the path element `s` is a major version suffix,
+// such as `v2`.
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(s[1:])
+	return err == nil
+}
+
+// localNames are the identifiers used by the generated code which packages
+// must not be imported as.
+var localNames = map[string]bool{
+	"visited": true,
+	"out":     true,
+	"v":       true,
+	"ok":      true,
+	"c":       true,
+	// builtins used by the generated code
+	"make":  true,
+	"len":   true,
+	"cap":   true,
+	"new":   true,
+	"panic": true,
+	"nil":   true,
+}
+
+// taken determines if `name` is used by the code being generated, so that a
+// package can't be imported with it.
+func (g *generator) taken(name string) bool {
+	if localNames[name] || name == g.ref || strings.HasPrefix(name, g.ref+"Copy") {
+		return true
+	}
+	// loop variables
+	if len(name) > 1 && (name[0] == 'i' || name[0] == 'v') {
+		if _, err := strconv.Atoi(name[1:]); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// importPkg records that the generated code uses the package at `importPath`,
+// named `pkgName` if it is known, and returns the name it is imported as.
+func (g *generator) importPkg(importPath, pkgName string) string {
+	name := g.names.name(importPath, pkgName, g.taken)
+	g.imports[importPath] = name
+	return name
+}
+
+// addImport adds the package of the passed in type, and of its type arguments,
+// to the list of imports if the type is in a different package than the root
+// object. Unnamed types add the packages of their element types.
+func (g *generator) addImport(t goType) {
+	if t.Name() == "" {
+		switch t.Kind() {
+		case reflect.Array, reflect.Slice, reflect.Ptr, reflect.Chan:
+			g.addImport(t.Elem())
+			return
+		case reflect.Map:
+			g.addImport(t.Key())
+			g.addImport(t.Elem())
+			return
+		}
+	}
+	if getPkgName(t) != g.rootPkg {
+		if pkgPath := t.PkgPath(); pkgPath != "" {
+			g.importPkg(pkgPath, t.PkgName())
+		}
+	}
+	for _, arg := range t.TypeArgs() {
+		g.addImport(arg)
+	}
+}
+
+// pkgAlias returns the name the package of the named type `t` is imported as,
+// or an empty string if the type belongs to the same package as the root
+// object (or to no package).
+func (g *generator) pkgAlias(t goType) string {
+	pkgPath := getPkgName(t)
+	if pkgPath == g.rootPkg || pkgPath == "" {
+		return ""
+	}
+	return g.names.name(pkgPath, t.PkgName(), g.taken)
+}
+
+// writeImports writes the import block for `imports`, which maps import paths
+// to the names they are imported as.
+// The standard library is imported first, followed by every other package,
+// sorted by path. Names are only written when they differ from the last
+// element of the path.
+func writeImports(buf *bytes.Buffer, imports map[string]string) {
+	if len(imports) == 0 {
+		return
+	}
+	var std, other []string
+	for p := range imports {
+		if isStdlib(p) {
+			std = append(std, p)
+		} else {
+			other = append(other, p)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+
+	buf.WriteString("import (\n")
+	for i, group := range [][]string{std, other} {
+		if i > 0 && len(std) > 0 && len(other) > 0 {
+			buf.WriteString("\n")
+		}
+		for _, p := range group {
+			if name := imports[p]; name != path.Base(p) {
+				fmt.Fprintf(buf, "%s %q\n", name, p)
+			} else {
+				fmt.Fprintf(buf, "%q\n", p)
+			}
+		}
+	}
+	buf.WriteString(")\n")
+}
+
+// isStdlib determines if the package at `importPath` is in the standard
+// library, which has no dots in the first element of its import paths.
+func isStdlib(importPath string) bool {
+	first := importPath
+	if i := strings.IndexByte(importPath, '/'); i >= 0 {
+		first = importPath[:i]
+	}
+	return !strings.Contains(first, ".")
+}
@@ -0,0 +1,91 @@
+package deepcopy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGuessPkgName(t *testing.T) {
+	cases := map[string]string{
+		"time":                         "time",
+		"math/big":                     "big",
+		"github.com/me/thing/v2":       "thing",
+		"gopkg.in/yaml.v3":             "yaml",
+		"github.com/cpuguy83/go-thing": "thing",
+		"example.com/foo-bar":          "foobar",
+	}
+	for p, expected := range cases {
+		if actual := guessPkgName(p); actual != expected {
+			t.Errorf("%s: expected %q, got %q", p, expected, actual)
+		}
+	}
+}
+
+func TestImportNames(t *testing.T) {
+	n := newImportNames("config")
+	taken := func(name string) bool { return name == "o" }
+
+	for _, c := range []struct {
+		path, pkgName, expected string
+	}{
+		{"example.com/a/util", "util", "util"},
+		{"example.com/b/util", "util", "util2"},
+		{"example.com/a/util", "util", "util"},
+		{"example.com/config", "config", "config2"},
+		{"example.com/o", "", "o2"},
+		{"example.com/c/util", "", "util3"},
+	} {
+		if actual := n.name(c.path, c.pkgName, taken); actual != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.path, c.expected, actual)
+		}
+	}
+}
+
+func TestWriteImports(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	writeImports(buf, map[string]string{
+		"time":                   "time",
+		"example.com/b/util":     "util2",
+		"bytes":                  "bytes",
+		"example.com/a/util":     "util",
+		"github.com/me/thing/v2": "thing",
+	})
+	expected := `import (
+"bytes"
+"time"
+
+"example.com/a/util"
+util2 "example.com/b/util"
+thing "github.com/me/thing/v2"
+)
+`
+	if buf.String() != expected {
+		t.Fatalf("expected: \n%s\ngot: \n%s", expected, buf)
+	}
+}
+
+func TestGenerateImportCollision(t *testing.T) {
+	// the package can't be imported with the name of the receiver
+	imports, copyFunc, err := GenerateWithOptions(structWithImports{}, Receiver("fixtures"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(imports, []byte(`fixtures2 "github.com/cpuguy83/go-generate/deepcopy/fixtures"`)) {
+		t.Fatalf("unexpected imports: %s", imports)
+	}
+	if !strings.Contains(string(copyFunc), "var fixturesCopy_A fixtures2.Foo") {
+		t.Fatalf("unexpected copy function: %s", copyFunc)
+	}
+
+	imports, copyFunc, err = GenerateWithOptions(structWithImportedArrays{}, Receiver("fixtures"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(imports, []byte(`fixtures2 "github.com/cpuguy83/go-generate/deepcopy/fixtures"`)) {
+		t.Fatalf("unexpected imports: %s", imports)
+	}
+	if !strings.Contains(string(copyFunc), "map[string][2]fixtures2.Foo") || strings.Contains(string(copyFunc), "]fixtures.Foo") {
+		t.Fatalf("unexpected copy function: %s", copyFunc)
+	}
+}
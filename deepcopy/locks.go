@@ -0,0 +1,155 @@
+package deepcopy
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"reflect"
+)
+
+// lockTypes are the types from the sync package which are left as the zero
+// value in the copy, since copying them would copy their state.
+var lockTypes = map[string]bool{
+	"sync.Mutex":     true,
+	"sync.RWMutex":   true,
+	"sync.WaitGroup": true,
+	"sync.Once":      true,
+}
+
+// isLock determines if `t` is one of the lock types from the sync package.
+func isLock(t goType) bool {
+	return lockTypes[typeKey(t)]
+}
+
+// isAtomic determines if `t` is one of the types from the sync/atomic package,
+// which are copied with their `Load` and `Store` methods.
+func isAtomic(t goType) bool {
+	return t.PkgPath() == "sync/atomic" && t.Kind() == reflect.Struct
+}
+
+// isSyncType determines if `t` must not be copied by assignment.
+func isSyncType(t goType) bool {
+	return isLock(t) || isAtomic(t)
+}
+
+// containsLock determines if values of type `t` hold a lock or an atomic
+// value, which means they cannot be copied by assignment.
+func containsLock(t goType) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		if isSyncType(t) {
+			return true
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if containsLock(t.Field(i).Type) {
+				return true
+			}
+		}
+	case reflect.Array:
+		return containsLock(t.Elem())
+	}
+	return false
+}
+
+// writeSyncValue writes the code to copy the lock or atomic value at `t`,
+// which must be a struct field. Locks are left as the zero value, atomic
+// values are loaded from the original and stored in the copy.
+func (g *generator) writeSyncValue(buf *bytes.Buffer, t *reflectType, copyStr, copyVal string) error {
+	if !isKind(t.parent, reflect.Struct) {
+		return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot make copy of %s outside of a struct field", t.goType))
+	}
+	if isAtomic(t.goType) {
+		g.writeAtomicCopy(buf, t.goType, copyStr, copyVal)
+	}
+	return nil
+}
+
+// writeAtomicCopy writes the code to store the value of the atomic `copyVal`
+// in the atomic `copyStr`.
+func (g *generator) writeAtomicCopy(buf *bytes.Buffer, t goType, copyStr, copyVal string) {
+	if t.Name() == "Value" {
+		// storing nil in an atomic.Value panics
+		fmt.Fprintf(buf, "if v := %s.Load(); v != nil {\n%s.Store(v)\n}\n", copyVal, copyStr)
+		return
+	}
+	fmt.Fprintf(buf, "%s.Store(%s.Load())\n", copyStr, copyVal)
+}
+
+// writeSyncPointer writes the code to copy a pointer to a lock or atomic
+// value, which is copied to a newly allocated value the same way as
+// `writeSyncValue`.
+func (g *generator) writeSyncPointer(buf *bytes.Buffer, t *reflectType, copyStr, copyVal string) {
+	g.addImport(t.Elem())
+	fmt.Fprintf(buf, "if %s != nil {\n%s = new(%s)\n", copyVal, copyStr, g.getName(t.Elem()))
+	if isAtomic(t.Elem()) {
+		g.writeAtomicCopy(buf, t.Elem(), copyStr, copyVal)
+	}
+	writeNilElse(buf, t, copyStr)
+	buf.WriteString("}\n")
+}
+
+// writeLockedFields writes the code to copy the fields of the struct at `t`,
+// which contains locks, by assignment since the struct cannot be assigned as a
+// whole. Fields which hold locks themselves are left to the caller.
+func (g *generator) writeLockedFields(buf *bytes.Buffer, t *reflectType, copyStr, copyVal string) error {
+	foreign := getPkgName(t.goType) != g.rootPkg
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if containsLock(field.Type) {
+			continue
+		}
+		if foreign && !token.IsExported(field.Name) {
+			if g.ignored[typeKey(t.goType)] {
+				continue
+			}
+			return wrapErr(ErrUnsettableField, fmt.Sprintf("cannot make copy of type '%v' with locks and unexported fields in another package", t.goType))
+		}
+		fmt.Fprintf(buf, "%s.%s = %s.%s\n", copyStr, field.Name, copyVal, field.Name)
+	}
+	return nil
+}
+
+// writeLockedMove writes the code to move the copy `src`, of the struct type
+// `t` which contains locks, to `dst` field by field, since the struct cannot
+// be assigned as a whole. Locks are left as they are in `dst`, and atomic
+// values are stored.
+func (g *generator) writeLockedMove(buf *bytes.Buffer, t goType, dst, src string) {
+	foreign := getPkgName(t) != g.rootPkg
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if foreign && !token.IsExported(field.Name) {
+			// only reached for ignored types, see writeLockedFields
+			continue
+		}
+		d, s := dst+"."+field.Name, src+"."+field.Name
+		switch {
+		case isLock(field.Type):
+		case isAtomic(field.Type):
+			g.writeAtomicCopy(buf, field.Type, d, s)
+		case containsLock(field.Type):
+			g.writeLockedMove(buf, field.Type, d, s)
+		default:
+			fmt.Fprintf(buf, "%s = %s\n", d, s)
+		}
+	}
+}
+
+// guardLock returns the field of the struct `t` which is tagged with
+// `deepcopy:"guard"`, to hold while copying the struct, along with the names
+// of the methods used to lock and unlock it.
+func guardLock(t goType) (field, lock, unlock string, err error) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if d, _ := parseDirective(f.Directive, f.Type); d.kind != directiveGuard {
+			continue
+		}
+		switch typeKey(f.Type) {
+		case "sync.Mutex":
+			return f.Name, "Lock", "Unlock", nil
+		case "sync.RWMutex":
+			return f.Name, "RLock", "RUnlock", nil
+		}
+		return "", "", "", wrapErr(ErrInvalidTag, fmt.Sprintf("deepcopy tag \"guard\" used on field %s which is not a sync.Mutex or sync.RWMutex", f.Name))
+	}
+	return "", "", "", nil
+}
@@ -0,0 +1,116 @@
+package deepcopy
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// marker is the comment prefix used to annotate types and fields in source.
+// Types are selected for generation with:
+//
+//	// +deepcopy
+//	type Foo struct {
+//		// +deepcopy:skip
+//		A map[string]string
+//	}
+//
+// A marker on a field has the same meaning as the `deepcopy` struct tag with
+// the same value, e.g. `// +deepcopy:skip` is the same as `deepcopy:"skip"`.
+const marker = "+deepcopy"
+
+// MarkedTypes returns the names of the types in the package which are
+// annotated with a `// +deepcopy` comment, sorted by name.
+func (p *Package) MarkedTypes() []string {
+	return append([]string(nil), p.types...)
+}
+
+// parseMarkers finds the marked types and fields in the passed in files.
+func parseMarkers(files []*ast.File, info *types.Info) (typeNames []string, fields map[*types.Var]string) {
+	fields = make(map[*types.Var]string)
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				doc := ts.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+				if v, ok := findMarker(doc); ok && v == "" {
+					typeNames = append(typeNames, ts.Name.Name)
+				}
+			}
+		}
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			st, ok := n.(*ast.StructType)
+			if !ok {
+				return true
+			}
+			for _, field := range st.Fields.List {
+				v, ok := findMarker(field.Doc)
+				if !ok {
+					v, ok = findMarker(field.Comment)
+				}
+				if !ok || v == "" {
+					continue
+				}
+				for _, id := range fieldIdents(field) {
+					if obj, ok := info.Defs[id].(*types.Var); ok {
+						fields[obj] = v
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	sort.Strings(typeNames)
+	return typeNames, fields
+}
+
+// findMarker looks for a deepcopy marker in the passed in comments and returns
+// its value, which is empty for a bare `+deepcopy`.
+func findMarker(doc *ast.CommentGroup) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	for _, c := range doc.List {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if text == marker {
+			return "", true
+		}
+		if strings.HasPrefix(text, marker+":") {
+			return strings.TrimPrefix(text, marker+":"), true
+		}
+	}
+	return "", false
+}
+
+// fieldIdents returns the identifiers which define the passed in field.
+// For embedded fields this is the identifier of the type name.
+func fieldIdents(field *ast.Field) []*ast.Ident {
+	if len(field.Names) > 0 {
+		return field.Names
+	}
+
+	expr := field.Type
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.SelectorExpr:
+			return []*ast.Ident{e.Sel}
+		case *ast.Ident:
+			return []*ast.Ident{e}
+		default:
+			return nil
+		}
+	}
+}
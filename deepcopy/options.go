@@ -0,0 +1,259 @@
+package deepcopy
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Option configures optional behavior of the generator.
+type Option func(*options)
+
+type options struct {
+	ref             string
+	methodName      string
+	pointerReceiver bool
+	// ignored is keyed by the result of `typeKey` for each ignored type
+	ignored      map[string]bool
+	formatOutput bool
+	bestEffort   bool
+	// buildTags is the build constraint of generated files
+	buildTags string
+	// testHelperPrefix starts the names of the helpers in generated test
+	// files
+	testHelperPrefix string
+
+	// kubernetes generates `DeepCopyInto` and `DeepCopy` (and, if
+	// objectType is set, `DeepCopyObject`) instead of `Copy`
+	kubernetes       bool
+	objectType       string
+	objectImportPath string
+
+	preserveAliasing  bool
+	interfaceFallback Policy
+	chanPolicy        Policy
+	funcPolicy        Policy
+}
+
+// Policy determines how the generated code handles a value which the generator
+// does not know how to deep copy.
+type Policy int
+
+const (
+	// PolicyShare copies the value as is, so it is shared between the original
+	// and the copy.
+	PolicyShare Policy = iota
+	// PolicyNil leaves the value as nil in the copy.
+	PolicyNil
+	// PolicyError treats the value as an error.
+	PolicyError
+	// PolicyFresh makes a new, empty, channel with the same capacity as the
+	// original.
+	// It only applies to channels.
+	PolicyFresh
+	// PolicyPanic makes the generated code panic when it encounters the value.
+	// It only applies to interfaces, see `InterfaceFallback`.
+	PolicyPanic
+)
+
+var policyNames = map[Policy]string{
+	PolicyShare: "share",
+	PolicyNil:   "nil",
+	PolicyError: "error",
+	PolicyFresh: "fresh",
+	PolicyPanic: "panic",
+}
+
+func (p Policy) String() string {
+	if s, ok := policyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// ParsePolicy returns the policy with the passed in name, as returned by
+// `Policy.String`.
+func ParsePolicy(s string) (Policy, error) {
+	for p, name := range policyNames {
+		if name == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown policy: %q", s)
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		ref:        "o",
+		methodName: "Copy",
+		ignored:    make(map[string]bool),
+		chanPolicy: PolicyError,
+
+		testHelperPrefix: "deepcopy",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Receiver sets the name of the receiver of the generated method, e.g. `o`
+// would be `func(o MyObject)`. The default is `o`.
+func Receiver(name string) Option {
+	return func(o *options) {
+		o.ref = name
+	}
+}
+
+// MethodName sets the name of the generated method. The default is `Copy`.
+func MethodName(name string) Option {
+	return func(o *options) {
+		o.methodName = name
+	}
+}
+
+// PointerReceiver generates the method on a pointer to the type, returning a
+// pointer to the copy, even when the type itself is not a pointer.
+func PointerReceiver() Option {
+	return func(o *options) {
+		o.pointerReceiver = true
+	}
+}
+
+// Ignore ignores errors related to non-accessible types in other packages
+// from the types of the passed in objects.
+func Ignore(objs ...interface{}) Option {
+	return func(o *options) {
+		for _, obj := range objs {
+			o.ignored[typeKey(fromReflect(reflect.TypeOf(obj)))] = true
+		}
+	}
+}
+
+// IgnoreTypeNames is like `Ignore`, but takes type names qualified by import
+// path (e.g. `time.Time`), which is useful when generating from source.
+func IgnoreTypeNames(names ...string) Option {
+	return func(o *options) {
+		for _, name := range names {
+			o.ignored[name] = true
+		}
+	}
+}
+
+// BestEffort generates code for every field which can be copied, even when some
+// fields cannot. The fields which cannot be copied are left as they are after
+// assigning the struct (so are shared with the original), with a comment
+// saying why.
+//
+// The generated code is returned along with the error for the skipped fields,
+// which is a `*TypeError`, or `Errors` if there is more than one.
+func BestEffort() Option {
+	return func(o *options) {
+		o.bestEffort = true
+	}
+}
+
+// FormatOutput formats the generated code with gofmt.
+// By default it is left to the caller to format the code, usually after
+// adding it to the rest of the file.
+func FormatOutput() Option {
+	return func(o *options) {
+		o.formatOutput = true
+	}
+}
+
+// BuildTags adds a `//go:build` line with the build constraint `expr` (e.g.
+// `linux && !race`) to generated files, see `File`.
+// It has no effect on the code returned by `Generate` and the like.
+func BuildTags(expr string) Option {
+	return func(o *options) {
+		o.buildTags = expr
+	}
+}
+
+// TestHelperPrefix sets the prefix of the names of the helpers declared in the
+// test files generated by `GenerateTestFile` and the like, `deepcopy` by
+// default. Test files generated for different files in the same package must
+// have different prefixes, since each of them declares the helpers.
+func TestHelperPrefix(prefix string) Option {
+	return func(o *options) {
+		o.testHelperPrefix = prefix
+	}
+}
+
+// KubernetesStyle generates the methods expected by Kubernetes tooling
+// instead of a single `Copy` method:
+//
+//	func (in *T) DeepCopyInto(out *T)
+//	func (in *T) DeepCopy() *T
+//
+// Nested types which have a `DeepCopyInto` method are copied by calling it.
+// `MethodName` and `PointerReceiver` have no effect with this option.
+func KubernetesStyle() Option {
+	return func(o *options) {
+		o.kubernetes = true
+	}
+}
+
+// DeepCopyObject is like `KubernetesStyle`, but also generates a
+// `DeepCopyObject` method which returns the copy as the interface named `name`
+// from the package at `importPath`, e.g.
+// `DeepCopyObject("Object", "k8s.io/apimachinery/pkg/runtime")`.
+// An empty import path refers to the package being generated for.
+func DeepCopyObject(name, importPath string) Option {
+	return func(o *options) {
+		o.kubernetes = true
+		o.objectType = name
+		o.objectImportPath = importPath
+	}
+}
+
+// PreserveAliasing makes the generated code keep track of the pointers, maps
+// and slices it has already copied.
+// When two references in the source value point at the same object, the
+// copies will also point at the same (copied) object, and values which contain
+// cycles are copied with the same cycles rather than looping forever.
+//
+// This comes at the cost of a map lookup for every reference which is copied.
+func PreserveAliasing() Option {
+	return func(o *options) {
+		o.preserveAliasing = true
+	}
+}
+
+// InterfaceFallback sets the policy for the dynamic value of an interface which
+// is not one of the known implementations of the interface and does not have a
+// `Copy` or `DeepCopy` method returning the interface type.
+// The default is `PolicyShare`. Since any interface may hold such a value,
+// `PolicyError` makes generating a copy of a type containing an interface
+// fail with `ErrUnsupportedType`, unless the interface itself has a `Copy` or
+// `DeepCopy` method. With `PolicyPanic` the generated code panics when it
+// encounters such a value instead, since `Copy` cannot return an error; `Copy`
+// at runtime returns an error for it with either policy.
+func InterfaceFallback(p Policy) Option {
+	return func(o *options) {
+		o.interfaceFallback = p
+	}
+}
+
+// ChanPolicy sets the policy for channels.
+// The default is `PolicyError`, which makes generating a copy of a type
+// containing a channel fail with `ErrUnsupportedType`.
+//
+// The policy can be overridden for a single struct field by setting its
+// `deepcopy` tag (or `// +deepcopy:` marker) to the name of the policy, e.g.
+// `deepcopy:"fresh"`.
+func ChanPolicy(p Policy) Option {
+	return func(o *options) {
+		o.chanPolicy = p
+	}
+}
+
+// FuncPolicy sets the policy for funcs.
+// The default is `PolicyShare`. `PolicyFresh` does not apply to funcs.
+//
+// As with `ChanPolicy`, the policy can be overridden for a single struct field.
+func FuncPolicy(p Policy) Option {
+	return func(o *options) {
+		o.funcPolicy = p
+	}
+}
@@ -0,0 +1,71 @@
+package deepcopy
+
+import (
+	"reflect"
+	"sync"
+)
+
+// copier is a function registered to copy values of a type.
+type copier struct {
+	funcName   string
+	importPath string
+}
+
+// copiers holds the registered copiers by type key, see `typeKey`.
+// gen counts the registrations, so that the plans `Copy` cached before one
+// are not used after it.
+var copiers = struct {
+	sync.RWMutex
+	m   map[string]copier
+	gen uint64
+}{m: make(map[string]copier)}
+
+// RegisterCopier registers the function `funcName` in the package at
+// `importPath` to copy every value of type `t` in generated code, instead of
+// the generator walking the type (or calling its `Copy` method).
+// The function must take a value of type `t` and return its copy, e.g.
+// `func CloneHandle(h *Handle) *Handle`.
+// An empty `importPath` means the function is in the package being generated
+// for.
+//
+// It is safe to call concurrently with generation, the copier is used by any
+// generation started after it is registered. Since `Copy` cannot call it,
+// `Copy` fails for `t` from then on.
+func RegisterCopier(t reflect.Type, funcName, importPath string) {
+	RegisterCopierName(typeKey(fromReflect(t)), funcName, importPath)
+}
+
+// RegisterCopierName is like `RegisterCopier`, but takes the name of the type
+// qualified by its import path (e.g. `time.Time` or `*math/big.Int`), so it
+// can be used without a value of the type when generating from source.
+func RegisterCopierName(typeName, funcName, importPath string) {
+	copiers.Lock()
+	copiers.m[typeName] = copier{funcName: funcName, importPath: importPath}
+	copiers.gen++
+	copiers.Unlock()
+}
+
+// copiersGen returns the number of copiers registered so far.
+func copiersGen() uint64 {
+	copiers.RLock()
+	defer copiers.RUnlock()
+	return copiers.gen
+}
+
+// lookupCopier returns the copier registered for `t`, if there is one.
+func lookupCopier(t goType) (copier, bool) {
+	copiers.RLock()
+	c, ok := copiers.m[typeKey(t)]
+	copiers.RUnlock()
+	return c, ok
+}
+
+// callCopier returns the call to the copier `c` with the argument `v`, adding
+// the import of the copier's package if needed.
+func (g *generator) callCopier(c copier, v string) string {
+	fn := c.funcName
+	if c.importPath != "" && c.importPath != g.rootPkg {
+		fn = g.importPkg(c.importPath, "") + "." + fn
+	}
+	return fn + "(" + v + ")"
+}
@@ -0,0 +1,66 @@
+package deepcopy
+
+import (
+	"bytes"
+	"errors"
+	"go/format"
+	"reflect"
+	"testing"
+
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
+)
+
+func TestRegisterCopier(t *testing.T) {
+	_, _, err := Generate("o", structWithRegisteredCopiers{}, nil)
+	if err == nil {
+		t.Fatal("expected an error copying fixtures.Handle without a copier")
+	}
+
+	defer func() {
+		copiers.Lock()
+		delete(copiers.m, "github.com/cpuguy83/go-generate/deepcopy/fixtures.Handle")
+		delete(copiers.m, "*github.com/cpuguy83/go-generate/deepcopy.registered")
+		copiers.gen++
+		copiers.Unlock()
+	}()
+	RegisterCopier(reflect.TypeOf(fixtures.Handle{}), "CopyHandle", "github.com/cpuguy83/go-generate/deepcopy/fixtures")
+	RegisterCopierName("*github.com/cpuguy83/go-generate/deepcopy.registered", "copyRegistered", "")
+
+	xFmt, err := format.Source(structWithRegisteredCopiersX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(imports, copyFunc []byte, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := format.Source(append(imports, copyFunc...))
+		if err != nil {
+			t.Fatal(err.Error() + "\n" + string(copyFunc))
+		}
+		if !bytes.Equal(bytes.TrimSpace(actual), bytes.TrimSpace(xFmt)) {
+			t.Fatalf("expected: \n%s\n\ngot: \n%s\n\n", xFmt, actual)
+		}
+	}
+	check(Generate("o", structWithRegisteredCopiers{}, nil))
+	check(loadFixtures(t).Generate("o", "structWithRegisteredCopiers", nil))
+}
+
+func TestRegisterCopierAfterCopy(t *testing.T) {
+	if _, err := Copy(registeredHolder{E: &registered{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		copiers.Lock()
+		delete(copiers.m, "*github.com/cpuguy83/go-generate/deepcopy.registered")
+		copiers.gen++
+		copiers.Unlock()
+	}()
+	RegisterCopierName("*github.com/cpuguy83/go-generate/deepcopy.registered", "copyRegistered", "")
+
+	if _, err := Copy(registeredHolder{E: &registered{}}); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+}
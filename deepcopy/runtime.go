@@ -0,0 +1,788 @@
+package deepcopy
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// Copy makes a deep copy of `v` at runtime using reflection, for types which
+// no copy function has been generated for.
+// It takes the same `deepcopy` struct tags and options (such as `Ignore` and
+// the channel and func policies) as the generator, and delegates to the same
+// `Copy` (or `DeepCopy` or `Clone`) methods, but it differs where the
+// generated code is limited to what is known when it is generated:
+//   - the dynamic values of interfaces are deep copied according to their
+//     type, where the generated code shares those without a copy method (see
+//     `InterfaceFallback`), which only applies to values `Copy` cannot copy;
+//   - `func=<name>` tags and copiers registered with `RegisterCopier` can't be
+//     called, so they are reported as an `ErrUnsupportedType` error.
+//
+// The way each type is copied is worked out once and cached, so it is cheap
+// to call `Copy` again for the same type and options. The cache is never
+// emptied: it grows with every type and set of options `Copy` is called with,
+// and with the dynamic types of the interface values it copies. It is safe to
+// call `Copy` concurrently.
+//
+// Like the generated code, `Copy` loops forever on cyclic values unless the
+// `PreserveAliasing` option is set.
+func Copy(v interface{}, opts ...Option) (interface{}, error) {
+	if v == nil {
+		return nil, wrapErr(ErrUnsupportedType, "cannot make copy of nil")
+	}
+	t := reflect.TypeOf(v)
+	o := newOptions(opts)
+	s := plansFor(t, o)
+	if s.err != nil {
+		return nil, s.err
+	}
+	if len(s.errs) > 0 && !o.bestEffort {
+		return nil, errorList(s.errs)
+	}
+
+	src := reflect.New(t).Elem()
+	src.Set(reflect.ValueOf(v))
+	dst := reflect.New(t).Elem()
+	st := &copyState{}
+	if o.preserveAliasing {
+		st.visited = make(map[visitKey]reflect.Value)
+	}
+	if err := s.root.run(st, dst, src); err != nil {
+		return nil, err
+	}
+	return dst.Interface(), errorList(s.errs)
+}
+
+// planCacheKey identifies a set of copy plans, which depend on the type being
+// copied, on the options and on the registered copiers.
+type planCacheKey struct {
+	t       reflect.Type
+	opts    string
+	copiers uint64
+}
+
+// planCache holds a *planSet for each planCacheKey.
+var planCache sync.Map
+
+// plansFor returns the plans to copy values of type `t` with the options `o`.
+func plansFor(t reflect.Type, o options) *planSet {
+	key := planCacheKey{t: t, opts: o.cacheKey(), copiers: copiersGen()}
+	if s, ok := planCache.Load(key); ok {
+		return s.(*planSet)
+	}
+
+	s := &planSet{
+		g: &generator{
+			options: o,
+			rootPkg: getPkgName(fromReflect(t)),
+			names:   newImportNames(),
+			funcs:   []copyFunc{{t: fromReflect(t), method: true}},
+		},
+		plans: make(map[planKey]*plan),
+	}
+	s.g.rootName = strings.TrimPrefix(s.g.getName(fromReflect(t)), "*")
+	s.mu.Lock()
+	s.root = s.planFor(t, s.g.rootName, noPolicy)
+	s.mu.Unlock()
+	s.err = s.root.err
+	s.errs = s.root.fieldErrs()
+
+	actual, _ := planCache.LoadOrStore(key, s)
+	return actual.(*planSet)
+}
+
+// cacheKey returns a string which is the same for all options that copy
+// values in the same way.
+func (o options) cacheKey() string {
+	ignored := make([]string, 0, len(o.ignored))
+	for k := range o.ignored {
+		ignored = append(ignored, k)
+	}
+	sort.Strings(ignored)
+	return fmt.Sprintf("%s|%t|%t|%v|%v|%v|%s", o.methodName, o.bestEffort, o.preserveAliasing, o.interfaceFallback, o.chanPolicy, o.funcPolicy, strings.Join(ignored, ","))
+}
+
+// noPolicy is used when a struct field does not override the channel or func
+// policy.
+const noPolicy Policy = -1
+
+// planKey identifies the plan for a type.
+// Channels and funcs can be copied differently depending on the directive of
+// the struct field holding them, which is passed on to the plans for the
+// values in the field.
+type planKey struct {
+	t      reflect.Type
+	policy Policy
+}
+
+// planSet holds the plans to copy a type, and every type reachable from it.
+type planSet struct {
+	// g holds the options and is used for the rules shared with the
+	// generator
+	g *generator
+
+	// mu guards plans, which plans for the dynamic types of interface values
+	// are added to while copying
+	mu    sync.RWMutex
+	plans map[planKey]*plan
+
+	root *plan
+	// err is set when the type can't be copied at all
+	err error
+	// errs are the errors for the fields which could not be copied
+	errs []*TypeError
+}
+
+// plan copies values of a single type.
+type plan struct {
+	// run copies `src` to `dst`, which must be addressable and accessible,
+	// see `accessible`.
+	run func(st *copyState, dst, src reflect.Value) error
+	// path is where the type was first found, which the paths of its errors
+	// start with
+	path string
+	// err is set when the type can't be copied
+	err error
+	// shallow is set when the values can be copied by assignment
+	shallow bool
+
+	// errs are the errors for the fields of the type which can't be copied,
+	// and deps the plans it runs, whose errors are the type's too
+	errs []*TypeError
+	deps []planDep
+
+	allErrsOnce sync.Once
+	allErrs     []*TypeError
+}
+
+// planDep is a plan run by another one for the values found at `path`.
+type planDep struct {
+	plan *plan
+	path string
+}
+
+// copyState is the state of a single call to `Copy`.
+type copyState struct {
+	// visited holds the copies of the references which have been copied,
+	// when aliasing is preserved
+	visited map[visitKey]reflect.Value
+}
+
+// visitKey identifies a reference.
+// Slices sharing a backing array are only the same if they are also the same
+// length.
+type visitKey struct {
+	t   reflect.Type
+	ptr uintptr
+	len int
+}
+
+// accessible returns `v` such that it can be read and, if it is addressable,
+// set even if it was reached through an unexported struct field.
+func accessible(v reflect.Value) reflect.Value {
+	if !v.CanAddr() || v.CanSet() {
+		return v
+	}
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+// hasKind determines if `t` is of one of the passed in kinds.
+func hasKind(t goType, kinds ...reflect.Kind) bool {
+	return isKind(&reflectType{goType: t}, kinds...)
+}
+
+// addressable returns an addressable copy of `v`.
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
+
+// planFor returns the plan for copying values of type `t`, found at `path`.
+// `s.mu` must be held.
+func (s *planSet) planFor(t reflect.Type, path string, policy Policy) *plan {
+	key := planKey{t: t, policy: policy}
+	if p, ok := s.plans[key]; ok {
+		return p
+	}
+	// the plan is stored before it is compiled so that recursive types refer
+	// back to it
+	p := &plan{path: path}
+	s.plans[key] = p
+	if err := s.compile(p, t, path, policy); err != nil {
+		p.err = annotate(err, path, fromReflect(t))
+		p.run = func(*copyState, reflect.Value, reflect.Value) error {
+			return p.err
+		}
+	}
+	return p
+}
+
+// dynamicPlan returns the plan for the dynamic type `t` of an interface value
+// found at `path`.
+func (s *planSet) dynamicPlan(t reflect.Type, path string) *plan {
+	s.mu.RLock()
+	p, ok := s.plans[planKey{t: t, policy: noPolicy}]
+	s.mu.RUnlock()
+	if ok {
+		return p
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.planFor(t, path, noPolicy)
+}
+
+// elemPlan returns the plan for copying the values of type `t` found at
+// `path` in the values copied by `p`, or the error if they can't be copied.
+// `s.mu` must be held.
+func (s *planSet) elemPlan(p *plan, t reflect.Type, path string, policy Policy) (*plan, error) {
+	elem := s.planFor(t, path, policy)
+	if elem.err != nil {
+		return nil, elem.errAt(path)
+	}
+	p.deps = append(p.deps, planDep{plan: elem, path: path})
+	return elem, nil
+}
+
+// errAt returns the error of `p` for the values found at `path`, rather than
+// where the type was first found.
+func (p *plan) errAt(path string) error {
+	if e, ok := p.err.(*TypeError); ok {
+		return rebase(e, p.path, path)
+	}
+	return p.err
+}
+
+// fieldErrs returns the errors for the fields which can't be copied in the
+// values copied by `p`, including those copied by the plans it runs.
+// Every plan reachable from `p` must be compiled.
+func (p *plan) fieldErrs() []*TypeError {
+	p.allErrsOnce.Do(func() {
+		p.collectErrs(&p.allErrs, p.path, make(map[*plan]bool))
+	})
+	return p.allErrs
+}
+
+// collectErrs adds the errors of `p`, and of the plans it runs, for the
+// values found at `path` to `errs`. `visiting` holds the plans being
+// collected, so that recursive types are only followed once.
+func (p *plan) collectErrs(errs *[]*TypeError, path string, visiting map[*plan]bool) {
+	if visiting[p] {
+		return
+	}
+	visiting[p] = true
+	defer delete(visiting, p)
+	for _, e := range p.errs {
+		addErr(errs, rebase(e, p.path, path))
+	}
+	for _, d := range p.deps {
+		d.plan.collectErrs(errs, path+strings.TrimPrefix(d.path, p.path), visiting)
+	}
+}
+
+// rebase returns `e` with the start `from` of its path replaced with `to`.
+func rebase(e *TypeError, from, to string) *TypeError {
+	if from == to || !strings.HasPrefix(e.Path, from) {
+		return e
+	}
+	c := *e
+	c.Path = to + strings.TrimPrefix(e.Path, from)
+	return &c
+}
+
+// compile sets `p.run` to copy values of type `t` following the same rules as
+// `writeFunc`.
+func (s *planSet) compile(p *plan, t reflect.Type, path string, policy Policy) error {
+	g, gt := s.g, fromReflect(t)
+
+	if c, ok := lookupCopier(gt); ok && !g.generated(gt) {
+		return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot call registered copier %s at runtime", c.funcName))
+	}
+	if b, ok := lookupBuiltin(gt); ok {
+		p.run = builtinPlan(t, b)
+		return nil
+	}
+
+	switch {
+	case isSyncType(gt):
+		return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot make copy of %s outside of a struct field", t))
+	case t.Kind() == reflect.Ptr && isSyncType(gt.Elem()):
+		p.run = func(st *copyState, dst, src reflect.Value) error {
+			if !src.IsNil() {
+				v := reflect.New(t.Elem())
+				copySyncValue(v.Elem(), src.Elem())
+				dst.Set(v)
+			}
+			return nil
+		}
+		return nil
+	case hasKind(gt, reflect.Slice, reflect.Array, reflect.Map) && containsLock(gt.Elem()):
+		return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot make copy of %s, which holds locks by value", t))
+	}
+
+	if t.Kind() != reflect.Interface && !g.generated(gt) && !(t.Kind() != reflect.Ptr && containsLock(gt)) {
+		name, indirect, err := g.copyMethod(gt)
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			p.run = methodPlan(t, name, indirect)
+			return nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func:
+		return s.compileRefPolicy(p, t, policy)
+	case reflect.Interface:
+		s.compileInterface(p, t, path)
+		return nil
+	case reflect.Struct:
+		return s.compileStruct(p, t, path)
+	case reflect.Ptr:
+		elem, err := s.elemPlan(p, t.Elem(), path, policy)
+		if err != nil {
+			return err
+		}
+		p.run = func(st *copyState, dst, src reflect.Value) error {
+			if src.IsNil() {
+				return nil
+			}
+			key := visitKey{t: t, ptr: src.Pointer()}
+			if st.visited != nil {
+				if v, ok := st.visited[key]; ok {
+					dst.Set(v)
+					return nil
+				}
+			}
+			v := reflect.New(t.Elem())
+			dst.Set(v)
+			if st.visited != nil {
+				st.visited[key] = v
+			}
+			return elem.run(st, v.Elem(), src.Elem())
+		}
+		return nil
+	case reflect.Array:
+		elem, err := s.elemPlan(p, t.Elem(), path+"[]", policy)
+		if err != nil {
+			return err
+		}
+		p.run = func(st *copyState, dst, src reflect.Value) error {
+			dst.Set(src)
+			if elem.shallow {
+				return nil
+			}
+			for i := 0; i < src.Len(); i++ {
+				if err := elem.run(st, dst.Index(i), src.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return nil
+	case reflect.Slice:
+		elem, err := s.elemPlan(p, t.Elem(), path+"[]", policy)
+		if err != nil {
+			return err
+		}
+		p.run = func(st *copyState, dst, src reflect.Value) error {
+			if src.IsNil() {
+				return nil
+			}
+			key := visitKey{t: t, ptr: src.Pointer(), len: src.Len()}
+			if st.visited != nil {
+				if v, ok := st.visited[key]; ok {
+					dst.Set(v)
+					return nil
+				}
+			}
+			v := reflect.MakeSlice(t, src.Len(), src.Len())
+			dst.Set(v)
+			if st.visited != nil {
+				st.visited[key] = v
+			}
+			if elem.shallow {
+				reflect.Copy(v, src)
+				return nil
+			}
+			for i := 0; i < src.Len(); i++ {
+				if err := elem.run(st, v.Index(i), src.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return nil
+	case reflect.Map:
+		elem, err := s.elemPlan(p, t.Elem(), path+"[]", policy)
+		if err != nil {
+			return err
+		}
+		p.run = func(st *copyState, dst, src reflect.Value) error {
+			if src.IsNil() {
+				return nil
+			}
+			key := visitKey{t: t, ptr: src.Pointer()}
+			if st.visited != nil {
+				if v, ok := st.visited[key]; ok {
+					dst.Set(v)
+					return nil
+				}
+			}
+			v := reflect.MakeMapWithSize(t, src.Len())
+			dst.Set(v)
+			if st.visited != nil {
+				st.visited[key] = v
+			}
+			iter := src.MapRange()
+			for iter.Next() {
+				if elem.shallow {
+					v.SetMapIndex(iter.Key(), iter.Value())
+					continue
+				}
+				// map values are not addressable
+				e := reflect.New(t.Elem()).Elem()
+				if err := elem.run(st, e, addressable(iter.Value())); err != nil {
+					return err
+				}
+				v.SetMapIndex(iter.Key(), e)
+			}
+			return nil
+		}
+		return nil
+	default:
+		p.shallow = true
+		p.run = func(st *copyState, dst, src reflect.Value) error {
+			dst.Set(src)
+			return nil
+		}
+		return nil
+	}
+}
+
+// compileRefPolicy sets `p.run` to copy the channel or func type `t` according
+// to its policy, see `writeRefPolicy`.
+func (s *planSet) compileRefPolicy(p *plan, t reflect.Type, policy Policy) error {
+	kind := "channel"
+	if policy == noPolicy {
+		policy = s.g.chanPolicy
+		if t.Kind() == reflect.Func {
+			policy = s.g.funcPolicy
+		}
+	}
+	if t.Kind() == reflect.Func {
+		kind = "func"
+	}
+
+	switch {
+	case policy == PolicyError:
+		return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot make copy of %s types", kind))
+	case policy == PolicyPanic:
+		return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot panic on %s values, the panic policy only applies to interfaces", kind))
+	case policy == PolicyFresh && t.Kind() != reflect.Chan:
+		return wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot make a fresh copy of %s types", kind))
+	}
+
+	p.run = func(st *copyState, dst, src reflect.Value) error {
+		switch policy {
+		case PolicyShare:
+			dst.Set(src)
+		case PolicyFresh:
+			if !src.IsNil() {
+				// channels can only be made in both directions
+				c := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, t.Elem()), src.Cap())
+				dst.Set(c.Convert(t))
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// compileInterface sets `p.run` to copy the interface type `t`, see
+// `writeInterfaceCopy`.
+// The dynamic value is copied with its `Copy` or `DeepCopy` method if it
+// returns the interface type, otherwise it is copied according to its type.
+// Values which can't be copied are handled by the interface fallback policy.
+func (s *planSet) compileInterface(p *plan, t reflect.Type, path string) {
+	fallback := s.g.interfaceFallback
+	if m, ok := copierMethod(fromReflect(t), fromReflect(t)); ok {
+		p.run = func(st *copyState, dst, src reflect.Value) error {
+			if !src.IsNil() {
+				dst.Set(src.MethodByName(m).Call(nil)[0])
+			}
+			return nil
+		}
+		return
+	}
+
+	p.run = func(st *copyState, dst, src reflect.Value) error {
+		if src.IsNil() {
+			return nil
+		}
+		v := src.Elem()
+		if m, ok := copierMethod(fromReflect(v.Type()), fromReflect(t)); ok {
+			dst.Set(v.MethodByName(m).Call(nil)[0])
+			return nil
+		}
+
+		// like the generator, values are only copied according to their
+		// type if all of their fields can be copied
+		dp := s.dynamicPlan(v.Type(), path+".("+v.Type().String()+")")
+		if dp.err == nil && len(dp.fieldErrs()) == 0 {
+			c := reflect.New(v.Type()).Elem()
+			if err := dp.run(st, c, addressable(v)); err != nil {
+				return err
+			}
+			dst.Set(c)
+			return nil
+		}
+
+		switch fallback {
+		case PolicyShare:
+			dst.Set(src)
+		case PolicyError, PolicyPanic:
+			return annotate(wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot make copy of %s", v.Type())), path, fromReflect(t))
+		}
+		return nil
+	}
+}
+
+// compileStruct sets `p.run` to copy the struct type `t`, with a plan for each
+// of its fields following the field's directive, see `generateField`.
+func (s *planSet) compileStruct(p *plan, t reflect.Type, path string) error {
+	g, gt := s.g, fromReflect(t)
+	locked := containsLock(gt)
+	foreign := getPkgName(gt) != g.rootPkg
+
+	guard, lock, _, err := guardLock(gt)
+	if err != nil {
+		return err
+	}
+	guardIndex := -1
+	if guard != "" {
+		f, _ := t.FieldByName(guard)
+		guardIndex = f.Index[0]
+	}
+
+	type fieldPlan struct {
+		index int
+		// assign is set for the fields which have to be assigned one by
+		// one, when the struct holds locks
+		assign bool
+		zero   bool
+		plan   *plan
+	}
+	var fields []fieldPlan
+	for i := 0; i < t.NumField(); i++ {
+		field := gt.Field(i)
+		ft := t.Field(i).Type
+		fp := fieldPlan{index: i}
+		fieldPath := path + "." + field.Name
+		fail := func(err error) {
+			err = annotate(err, fieldPath, field.Type)
+			if typeErr, ok := err.(*TypeError); ok {
+				addErr(&p.errs, typeErr)
+			}
+		}
+
+		if locked && !containsLock(field.Type) {
+			if foreign && !token.IsExported(field.Name) {
+				if g.ignored[typeKey(gt)] {
+					continue
+				}
+				return wrapErr(ErrUnsettableField, fmt.Sprintf("cannot make copy of type '%v' with locks and unexported fields in another package", t))
+			}
+			fp.assign = true
+		}
+
+		d, err := parseDirective(field.Directive, field.Type)
+		if err != nil {
+			fail(err)
+			fields = append(fields, fp)
+			continue
+		}
+		switch d.kind {
+		case directiveShallow:
+			fields = append(fields, fp)
+			continue
+		case directiveZero, directiveNil, directiveFunc:
+			if foreign && !token.IsExported(field.Name) {
+				fail(wrapErr(ErrUnsettableField, fmt.Sprintf("cannot set unexported field of type '%v' in another package", t)))
+			} else if d.kind == directiveFunc {
+				fail(wrapErr(ErrUnsupportedType, fmt.Sprintf("cannot call %s at runtime", d.fn)))
+			} else if d.kind == directiveNil && !hasKind(field.Type, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func, reflect.Interface, reflect.UnsafePointer) {
+				fail(wrapErr(ErrInvalidTag, fmt.Sprintf("deepcopy tag \"nil\" used on %s field which cannot be nil", ft.Kind())))
+			} else {
+				fp.zero = true
+			}
+			fields = append(fields, fp)
+			continue
+		}
+
+		if isSyncType(field.Type) {
+			if isAtomic(field.Type) {
+				fp.plan = &plan{run: func(st *copyState, dst, src reflect.Value) error {
+					copySyncValue(dst, src)
+					return nil
+				}}
+			}
+			fields = append(fields, fp)
+			continue
+		}
+
+		if nextPkg := getPkgName(field.Type); nextPkg != "" && nextPkg != g.rootPkg {
+			name := g.getName(field.Type)
+			if strings.ToLower(name) == name {
+				if !g.ignored[typeKey(field.Type)] {
+					fail(wrapErr(ErrUnexportedType, fmt.Sprintf("cannot use type: %s", ft)))
+				}
+				fields = append(fields, fp)
+				continue
+			}
+		}
+		if foreign && !token.IsExported(field.Name) && hasKind(field.Type, reflect.Map, reflect.Ptr, reflect.Array, reflect.Slice) {
+			if !g.ignored[typeKey(gt)] {
+				fail(wrapErr(ErrUnsettableField, fmt.Sprintf("cannot make copy of type '%v' with unexported field in another package: %s", t, field.Name)))
+			}
+			fields = append(fields, fp)
+			continue
+		}
+
+		fieldPolicy := noPolicy
+		if p, err := ParsePolicy(field.Directive); err == nil {
+			fieldPolicy = p
+		}
+		next, err := s.elemPlan(p, ft, fieldPath, fieldPolicy)
+		if err != nil {
+			fail(err)
+		} else if !next.shallow {
+			fp.plan = next
+		}
+		fields = append(fields, fp)
+	}
+
+	p.run = func(st *copyState, dst, src reflect.Value) error {
+		if guardIndex >= 0 && src.CanAddr() {
+			mu := accessible(src.Field(guardIndex)).Addr().Interface()
+			if lock == "RLock" {
+				mu.(*sync.RWMutex).RLock()
+				defer mu.(*sync.RWMutex).RUnlock()
+			} else {
+				mu.(*sync.Mutex).Lock()
+				defer mu.(*sync.Mutex).Unlock()
+			}
+		}
+		if !locked {
+			dst.Set(src)
+		}
+		for _, f := range fields {
+			d, v := accessible(dst.Field(f.index)), accessible(src.Field(f.index))
+			switch {
+			case f.zero:
+				d.Set(reflect.Zero(d.Type()))
+				continue
+			case f.assign:
+				d.Set(v)
+			}
+			if f.plan != nil {
+				if err := f.plan.run(st, d, v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// addErr adds the error for a field which can't be copied to `errs`, unless
+// an error has already been recorded at its path.
+func addErr(errs *[]*TypeError, err *TypeError) {
+	for _, e := range *errs {
+		if e.Path == err.Path {
+			return
+		}
+	}
+	*errs = append(*errs, err)
+}
+
+// builtinPlan returns the function to copy the standard library type `t` with
+// `b`, see `writeBuiltin`.
+func builtinPlan(t reflect.Type, b builtin) func(st *copyState, dst, src reflect.Value) error {
+	return func(st *copyState, dst, src reflect.Value) error {
+		switch b.kind {
+		case builtinShare:
+			dst.Set(src)
+		case builtinClone:
+			if !src.IsNil() {
+				v := reflect.MakeSlice(t, src.Len(), src.Len())
+				reflect.Copy(v, src)
+				dst.Set(v)
+			}
+		case builtinSet:
+			if !src.IsNil() {
+				v := reflect.New(t.Elem())
+				v.MethodByName(b.method).Call([]reflect.Value{src})
+				dst.Set(v)
+			}
+		case builtinDeref:
+			if !src.IsNil() {
+				v := reflect.New(t.Elem())
+				v.Elem().Set(src.Elem())
+				dst.Set(v)
+			}
+		}
+		return nil
+	}
+}
+
+// methodPlan returns the function to copy values of type `t` with their copy
+// method `name`, see `copyMethod`.
+func methodPlan(t reflect.Type, name string, indirect bool) func(st *copyState, dst, src reflect.Value) error {
+	return func(st *copyState, dst, src reflect.Value) error {
+		recv := src
+		if t.Kind() == reflect.Ptr {
+			if src.IsNil() {
+				return nil
+			}
+		} else {
+			// the method may have a pointer receiver
+			recv = addressable(src).Addr()
+		}
+		v := recv.MethodByName(name).Call(nil)[0]
+		switch {
+		case !indirect:
+			dst.Set(v)
+		case t.Kind() == reflect.Ptr:
+			c := reflect.New(t.Elem())
+			c.Elem().Set(v)
+			dst.Set(c)
+		case !v.IsNil():
+			dst.Set(v.Elem())
+		}
+		return nil
+	}
+}
+
+// copySyncValue copies the lock or atomic value `src` to `dst`, both of which
+// must be addressable. Locks are left as the zero value.
+func copySyncValue(dst, src reflect.Value) {
+	if !isAtomic(fromReflect(src.Type())) {
+		return
+	}
+	v := src.Addr().MethodByName("Load").Call(nil)[0]
+	if src.Type().Name() == "Value" && v.IsNil() {
+		// storing nil in an atomic.Value panics
+		return
+	}
+	dst.Addr().MethodByName("Store").Call([]reflect.Value{v})
+}
@@ -0,0 +1,278 @@
+package deepcopy
+
+import (
+	"errors"
+	"math/big"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cpuguy83/go-generate/deepcopy/fixtures"
+)
+
+// copyCases returns values of the fixture types, with every reference set,
+// to copy at runtime.
+func copyCases() []interface{} {
+	name := "name"
+	return []interface{}{
+		simpleStruct{A: "a", b: "b"},
+		mapOfSlices{"a": {"b", "c"}, "d": nil},
+		complexStruct{
+			A: "a",
+			B: map[string]int{"b": 1},
+			C: []*simpleStruct{{A: "c"}, nil},
+			D: map[string]*simpleStruct{"d": {b: "d"}},
+			E: [][]*simpleStruct{{{A: "e"}}},
+			F: [2][]*simpleStruct{{{A: "f"}}},
+			G: [2]simpleStruct{{A: "g"}},
+			H: &anotherStruct{
+				simpleStruct: simpleStruct{A: "h"},
+				X:            map[string]*struct{ A *string }{"x": {A: &name}},
+				Z:            map[string]*string{"z": &name},
+			},
+		},
+		linkedList{Value: "a", Next: &linkedList{Value: "b"}},
+		&ptrTree{Left: &ptrTree{}, Right: &ptrTree{Left: &ptrTree{}}},
+		structPtrWithCopyMethod{A: &fixtures.Apple{A: "apple"}},
+		structWithStdlibTypes{
+			T:   time.Unix(1, 0),
+			I:   big.NewInt(42),
+			F:   big.NewFloat(4.2),
+			IP:  net.IPv4(127, 0, 0, 1),
+			IPs: []net.IP{net.IPv6loopback},
+			M:   map[string]*big.Rat{"r": big.NewRat(1, 2), "nil": nil},
+		},
+		doubleSliceWithStructPtr{{{A: "a"}, nil}, nil},
+		mapOfMaps{"a": {"b": {}}},
+		mapOfArrays{"a": {{A: "a"}, nil}},
+		nestedMap{"a": {"b": nil}},
+		structWithEmbeddedPointer{A: &struct{ B string }{B: "b"}},
+		sharedRefs{A: []string{"a"}, B: []string{"b"}, M: map[string]*simpleStruct{"m": {A: "m"}}},
+		tree{Root: &treeNode{Children: []*treeNode{{Labels: map[string]string{"a": "b"}}}}},
+		structWithInterfaces{A: &polygon{Sides: []int{1}}, C: []shape{square{Side: 1}, &polygon{}}},
+	}
+}
+
+func TestCopy(t *testing.T) {
+	for _, c := range copyCases() {
+		t.Run(reflect.TypeOf(c).String(), func(t *testing.T) {
+			actual, err := Copy(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, c) {
+				t.Fatalf("expected: %#v\ngot: %#v", c, actual)
+			}
+		})
+	}
+}
+
+func TestCopyIsDeep(t *testing.T) {
+	o := complexStruct{
+		B: map[string]int{"b": 1},
+		C: []*simpleStruct{{A: "c"}},
+		F: [2][]*simpleStruct{{{A: "f"}}},
+	}
+	v, err := Copy(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := v.(complexStruct)
+	c.B["b"] = 2
+	c.C[0].A = "changed"
+	c.F[0][0].A = "changed"
+	if o.B["b"] != 1 || o.C[0].A != "c" || o.F[0][0].A != "f" {
+		t.Fatalf("original was changed through the copy: %#v", o)
+	}
+}
+
+func TestCopyLocks(t *testing.T) {
+	o := &structWithLocks{
+		Name:  "locked",
+		Tags:  []string{"a"},
+		Inner: lockedInner{M: map[string]int{"a": 1}},
+		Ptr:   &lockedInner{M: map[string]int{"b": 2}},
+		Once:  new(sync.Once),
+	}
+	o.hits.Store(3)
+	o.val.Store("val")
+	o.Inner.mu.Lock()
+	defer o.Inner.mu.Unlock()
+
+	v, err := Copy(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := v.(*structWithLocks)
+	if c.hits.Load() != 3 || c.val.Load() != "val" || c.Name != "locked" {
+		t.Fatalf("unexpected copy: %+v", c)
+	}
+	if !c.Inner.mu.TryLock() {
+		t.Fatal("expected the lock to be left unlocked in the copy")
+	}
+	c.Inner.M["a"] = 2
+	c.Ptr.M["b"] = 3
+	c.Tags[0] = "b"
+	if o.Inner.M["a"] != 1 || o.Ptr.M["b"] != 2 || o.Tags[0] != "a" {
+		t.Fatalf("original was changed through the copy: %+v", o)
+	}
+	if c.Once == nil || c.Once == o.Once {
+		t.Fatalf("expected a new sync.Once, got: %p", c.Once)
+	}
+}
+
+func TestCopyTags(t *testing.T) {
+	type tagged struct {
+		A map[string]string `deepcopy:"shallow"`
+		B []string          `deepcopy:"zero"`
+		D *simpleStruct     `deepcopy:"nil"`
+		G []string
+	}
+	o := tagged{
+		A: map[string]string{"a": "a"},
+		B: []string{"b"},
+		D: &simpleStruct{},
+		G: []string{"g"},
+	}
+	v, err := Copy(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := v.(tagged)
+	if c.B != nil || c.D != nil || !reflect.DeepEqual(c.G, o.G) {
+		t.Fatalf("unexpected copy: %+v", c)
+	}
+	c.A["a"] = "b"
+	c.G[0] = "h"
+	if o.A["a"] != "b" || o.G[0] != "g" {
+		t.Fatalf("unexpected original: %+v", o)
+	}
+
+	if _, err := Copy(structWithTags{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+	if _, err := Copy(structWithUnknownTag{}); !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("expected '%v', got: %v", ErrInvalidTag, err)
+	}
+}
+
+func TestCopyErrors(t *testing.T) {
+	_, err := Copy(worker{})
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expected Errors, got: %T: %v", err, err)
+	}
+	if len(errs) != 2 || errs[0].Path != "worker.done" || errs[1].Path != "worker.errs" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	_, err = Copy(pathRoot{})
+	var e *TypeError
+	if !errors.As(err, &e) || e.Path != "pathRoot.Items[].Owner.done" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := Copy(structWithImportsAndUnsettableFields{}); !errors.Is(err, ErrUnsettableField) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsettableField, err)
+	}
+	o := structWithImportsAndUnsettableFields{A: &fixtures.Banana{}}
+	v, err := Copy(o, Ignore(fixtures.Banana{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := v.(structWithImportsAndUnsettableFields); c.A == o.A {
+		t.Fatal("expected the ignored type to be copied by assignment to a new pointer")
+	}
+}
+
+func TestCopyPolicies(t *testing.T) {
+	o := worker{
+		done:     make(chan struct{}),
+		jobs:     make(chan int, 3),
+		results:  []chan int{make(chan int)},
+		callback: func() error { return nil },
+	}
+	v, err := Copy(o, ChanPolicy(PolicyShare), FuncPolicy(PolicyShare))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := v.(worker)
+	if c.done != o.done || len(c.results) != 1 || c.results[0] != nil || c.callback == nil {
+		t.Fatalf("unexpected copy: %+v", c)
+	}
+	if c.jobs == o.jobs || cap(c.jobs) != 3 {
+		t.Fatalf("expected a fresh channel, got: %v", c.jobs)
+	}
+
+	v, err = Copy(o, BestEffort())
+	if _, ok := err.(Errors); !ok {
+		t.Fatalf("expected Errors, got: %T: %v", err, err)
+	}
+	if c := v.(worker); c.done != o.done {
+		t.Fatalf("expected the channel to be shared, got: %v", c.done)
+	}
+}
+
+// TestCopyCachedErrors checks that the errors of a type are the same when its
+// plan has already been made for another one.
+func TestCopyCachedErrors(t *testing.T) {
+	type pB struct{ Ch chan int }
+	type pA struct{ X pB }
+	type pHolder struct{ I interface{} }
+	type pPair struct{ A, B pB }
+
+	opt := InterfaceFallback(PolicyError)
+	if _, err := Copy(pHolder{I: pA{X: pB{Ch: make(chan int)}}}, opt); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+	if _, err := Copy(pHolder{I: pB{Ch: make(chan int)}}, opt); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+
+	// the fields share the plan for pB
+	_, err := Copy(pPair{})
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 2 || errs[0].Path != "pPair.A.Ch" || errs[1].Path != "pPair.B.Ch" {
+		t.Fatalf("unexpected errors: %v", err)
+	}
+}
+
+func TestCopyPreserveAliasing(t *testing.T) {
+	root := &treeNode{Labels: map[string]string{"a": "b"}}
+	child := &treeNode{Parent: root}
+	root.Children = []*treeNode{child, child}
+
+	v, err := Copy(tree{Root: root}, PreserveAliasing())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := v.(tree)
+	if c.Root == root || c.Root.Children[0] == child {
+		t.Fatal("expected the nodes to be copied")
+	}
+	if c.Root.Children[0] != c.Root.Children[1] || c.Root.Children[0].Parent != c.Root {
+		t.Fatal("expected the aliasing of the original to be preserved")
+	}
+}
+
+func TestCopyConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o := linkedList{Value: "a", Next: &linkedList{Value: "b"}}
+			v, err := Copy(o)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !reflect.DeepEqual(v, o) {
+				t.Errorf("expected: %#v\ngot: %#v", o, v)
+			}
+		}()
+	}
+	wg.Wait()
+}
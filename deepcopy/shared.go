@@ -0,0 +1,189 @@
+package deepcopy
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// SharedReferences walks `a` and `b`, such as a value and its copy, in
+// lockstep and returns the paths of the references they share: the same
+// pointer, map or channel, or slices backed by the same array. It is meant
+// to be used in tests to check that a copy method, generated or not, makes a
+// deep copy:
+//
+//	if shared := deepcopy.SharedReferences(foo, foo.Copy()); len(shared) > 0 {
+//		t.Fatalf("the copy shares %v with the original", shared)
+//	}
+//
+// Paths start with the name of the type, like the paths of a `*TypeError`,
+// but have the index or key of the element rather than `[]`, e.g.
+// `Foo.Items[2].Owner` or `Foo.Labels["a"]`. A reference which is shared is
+// reported once, and the values it leads to are not compared further.
+//
+// Only the values which are in both are compared: the elements of slices up
+// to the shorter length, the keys of maps which are in both, and interfaces
+// holding the same type. Func values, strings and values of size zero can't
+// be changed through a shared reference, so they are never reported, nor are
+// the types from the standard library which the generated code shares since
+// they are immutable, such as `time.Time` and `*regexp.Regexp`. `nil` is
+// returned if `a` and `b` are not of the same type.
+// Unexported fields are compared too, and cycles are followed once.
+func SharedReferences(a, b interface{}) []string {
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !av.IsValid() || !bv.IsValid() || av.Type() != bv.Type() {
+		return nil
+	}
+	t := av.Type()
+	root := baseType(t).Name()
+	if root == "" {
+		root = t.String()
+	}
+
+	w := &sharedWalker{visited: make(map[sharedVisit]bool), immutable: make(map[reflect.Type]bool)}
+	w.walk(av, bv, root)
+	return w.paths
+}
+
+// sharedVisit identifies a pair of references which has already been walked.
+type sharedVisit struct {
+	t    reflect.Type
+	a, b uintptr
+}
+
+// sharedWalker walks two values in lockstep, see `SharedReferences`.
+type sharedWalker struct {
+	paths   []string
+	visited map[sharedVisit]bool
+	// immutable caches whether each type is shared by the generated code
+	immutable map[reflect.Type]bool
+}
+
+// isImmutable determines if `t` is one of the types from the standard library
+// which the generated code shares between the original and the copy.
+func (w *sharedWalker) isImmutable(t reflect.Type) bool {
+	immutable, ok := w.immutable[t]
+	if !ok {
+		b, isBuiltin := lookupBuiltin(fromReflect(t))
+		immutable = isBuiltin && b.kind == builtinShare
+		w.immutable[t] = immutable
+	}
+	return immutable
+}
+
+// visit determines if the references `a` and `b` are walked for the first
+// time, and marks them as walked.
+func (w *sharedWalker) visit(a, b reflect.Value) bool {
+	key := sharedVisit{t: a.Type(), a: a.Pointer(), b: b.Pointer()}
+	if w.visited[key] {
+		return false
+	}
+	w.visited[key] = true
+	return true
+}
+
+func (w *sharedWalker) walk(a, b reflect.Value, path string) {
+	if w.isImmutable(a.Type()) {
+		return
+	}
+	switch a.Kind() {
+	case reflect.Ptr:
+		if a.IsNil() || b.IsNil() || a.Type().Elem().Size() == 0 {
+			return
+		}
+		if a.Pointer() == b.Pointer() {
+			w.paths = append(w.paths, path)
+			return
+		}
+		if w.visit(a, b) {
+			w.walk(a.Elem(), b.Elem(), path)
+		}
+	case reflect.Chan:
+		if !a.IsNil() && a.Pointer() == b.Pointer() {
+			w.paths = append(w.paths, path)
+		}
+	case reflect.Map:
+		if a.IsNil() || b.IsNil() {
+			return
+		}
+		if a.Pointer() == b.Pointer() {
+			w.paths = append(w.paths, path)
+			return
+		}
+		if !w.visit(a, b) {
+			return
+		}
+		// the keys are sorted so that the paths are in the same order every
+		// time. Different keys may still have the same path, e.g. NaNs, so
+		// they are not looked up by it.
+		keys := a.MapKeys()
+		names := make([]string, len(keys))
+		for i, k := range keys {
+			names[i] = mapKeyPath(k)
+		}
+		sort.Sort(mapKeys{keys: keys, names: names})
+		for i, k := range keys {
+			if bElem := b.MapIndex(k); bElem.IsValid() {
+				w.walk(a.MapIndex(k), bElem, path+names[i])
+			}
+		}
+	case reflect.Slice:
+		size := a.Type().Elem().Size()
+		if a.Cap() == 0 || b.Cap() == 0 || size == 0 {
+			return
+		}
+		// the arrays backing the slices overlap, as far as they can be
+		// appended to
+		aStart, bStart := a.Pointer(), b.Pointer()
+		if aStart < bStart+uintptr(b.Cap())*size && bStart < aStart+uintptr(a.Cap())*size {
+			w.paths = append(w.paths, path)
+			return
+		}
+		for i := 0; i < a.Len() && i < b.Len(); i++ {
+			w.walk(a.Index(i), b.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			w.walk(a.Index(i), b.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() || a.Elem().Type() != b.Elem().Type() {
+			return
+		}
+		w.walk(a.Elem(), b.Elem(), path)
+	case reflect.Struct:
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			w.walk(a.Field(i), b.Field(i), path+"."+t.Field(i).Name)
+		}
+	}
+}
+
+// mapKeyPath returns the path element for the map key `k`, quoted if it is a
+// string. The values of interface keys are prefixed with their type, so that
+// e.g. 1 and int64(1) have different paths.
+func mapKeyPath(k reflect.Value) string {
+	if k.Kind() == reflect.Interface && !k.IsNil() {
+		k = k.Elem()
+		if k.Type() != reflect.TypeOf("") {
+			return fmt.Sprintf("[%s(%v)]", k.Type(), k)
+		}
+	}
+	if k.Kind() == reflect.String {
+		return fmt.Sprintf("[%q]", k.String())
+	}
+	return fmt.Sprintf("[%v]", k)
+}
+
+// mapKeys sorts the keys of a map by their paths.
+type mapKeys struct {
+	keys  []reflect.Value
+	names []string
+}
+
+func (m mapKeys) Len() int           { return len(m.keys) }
+func (m mapKeys) Less(i, j int) bool { return m.names[i] < m.names[j] }
+func (m mapKeys) Swap(i, j int) {
+	m.keys[i], m.keys[j] = m.keys[j], m.keys[i]
+	m.names[i], m.names[j] = m.names[j], m.names[i]
+}
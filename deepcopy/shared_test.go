@@ -0,0 +1,103 @@
+package deepcopy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSharedReferences(t *testing.T) {
+	name := "name"
+	o := complexStruct{
+		B: map[string]int{"b": 1},
+		C: []*simpleStruct{{A: "c"}, {A: "d"}},
+		D: map[string]*simpleStruct{"a": {}, "b": {}},
+		E: [][]*simpleStruct{{{A: "e"}}},
+		H: &anotherStruct{Z: map[string]*string{"z": &name}},
+	}
+	if shared := SharedReferences(o, o); !reflect.DeepEqual(shared, []string{"complexStruct.B", "complexStruct.C", "complexStruct.D", "complexStruct.E", "complexStruct.H"}) {
+		t.Fatalf("unexpected shared references: %q", shared)
+	}
+
+	c := complexStruct{
+		B: map[string]int{"b": 1},
+		C: []*simpleStruct{o.C[0], {A: "d"}, {}},
+		D: map[string]*simpleStruct{"a": {}, "b": o.D["b"]},
+		E: [][]*simpleStruct{o.E[0][:0:0], {}},
+		H: &anotherStruct{Z: map[string]*string{"z": &name}},
+	}
+	expected := []string{"complexStruct.C[0]", `complexStruct.D["b"]`, "complexStruct.H.Z[\"z\"]"}
+	if shared := SharedReferences(o, c); !reflect.DeepEqual(shared, expected) {
+		t.Fatalf("expected %q, got: %q", expected, shared)
+	}
+
+	// slices which share part of their backing array
+	s := make([]string, 2, 4)
+	if shared := SharedReferences(sliceType(s[:1]), sliceType(s[1:])); !reflect.DeepEqual(shared, []string{"sliceType"}) {
+		t.Fatalf("unexpected shared references: %q", shared)
+	}
+	if shared := SharedReferences(sliceType(s[:1:1]), sliceType(s[1:])); shared != nil {
+		t.Fatalf("unexpected shared references: %q", shared)
+	}
+
+	// unexported fields, channels and interfaces
+	w := worker{done: make(chan struct{}), results: []chan int{make(chan int)}}
+	if shared := SharedReferences(&w, &worker{done: w.done, results: []chan int{w.results[0]}}); !reflect.DeepEqual(shared, []string{"worker.done", "worker.results[0]"}) {
+		t.Fatalf("unexpected shared references: %q", shared)
+	}
+	iface := structWithInterfaces{A: &square{}}
+	if shared := SharedReferences(iface, iface); !reflect.DeepEqual(shared, []string{"structWithInterfaces.A"}) {
+		t.Fatalf("unexpected shared references: %q", shared)
+	}
+
+	// interface keys which print the same
+	type keyed map[interface{}]*simpleStruct
+	p, q := &simpleStruct{}, &simpleStruct{}
+	if shared := SharedReferences(keyed{1: p, "1": q, int64(1): p}, keyed{1: {}, "1": q, int64(1): p}); !reflect.DeepEqual(shared, []string{`keyed["1"]`, "keyed[int64(1)]"}) {
+		t.Fatalf("unexpected shared references: %q", shared)
+	}
+
+	// cycles
+	root := &treeNode{}
+	root.Children = []*treeNode{{Parent: root}}
+	root2 := &treeNode{}
+	root2.Children = []*treeNode{{Parent: root2}}
+	if shared := SharedReferences(tree{Root: root}, tree{Root: root2}); shared != nil {
+		t.Fatalf("unexpected shared references: %q", shared)
+	}
+
+	if shared := SharedReferences(simpleStruct{}, &simpleStruct{}); shared != nil {
+		t.Fatalf("expected nothing for different types, got: %q", shared)
+	}
+}
+
+// TestCopySharesNothing checks that the copies made by `Copy` of the fixture
+// types share no references with the originals.
+func TestCopySharesNothing(t *testing.T) {
+	for _, c := range copyCases() {
+		t.Run(reflect.TypeOf(c).String(), func(t *testing.T) {
+			actual, err := Copy(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if shared := SharedReferences(c, actual); len(shared) > 0 {
+				t.Fatalf("the copy shares %q with the original", shared)
+			}
+		})
+	}
+}
+
+// TestFixtureCopyMethods checks that the copy methods written by hand for the
+// fixture types, which the generated code calls, make deep copies.
+func TestFixtureCopyMethods(t *testing.T) {
+	c := &cloner{M: map[string]string{"a": "b"}}
+	if shared := SharedReferences(c, c.Clone()); len(shared) > 0 {
+		t.Fatalf("the copy shares %q with the original", shared)
+	}
+
+	k := &kubeTemplate{Labels: map[string]string{"a": "b"}}
+	var kc kubeTemplate
+	k.DeepCopyInto(&kc)
+	if shared := SharedReferences(k, &kc); len(shared) > 0 {
+		t.Fatalf("the copy shares %q with the original", shared)
+	}
+}
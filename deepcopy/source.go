@@ -0,0 +1,548 @@
+package deepcopy
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+)
+
+// GenerateSource is like `Generate`, but instead of requiring a live value of
+// the type it loads the package from source and generates the copy function
+// from the type information there.
+// it takes:
+//   - `ref` is a string which is the name of the function reciever should use
+//   - `pkgPath` is the import path (or directory) of the package the type is defined in
+//   - `typeName` is the name of the type in that package to generate a copy function for
+//   - `ignore` is a list of type names, qualified by import path (e.g. `time.Time`),
+//     to ignore errors related to non-accessible types in other packages for
+//   - `opts` configures optional behavior of the generator, such as `PreserveAliasing`
+//
+// It returns the neccessary import statements and the generated copy function to use.
+func GenerateSource(ref, pkgPath, typeName string, ignore []string, opts ...Option) (importsBuf []byte, copyFnBuf []byte, err error) {
+	pkg, err := LoadPackage(pkgPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkg.Generate(ref, typeName, ignore, opts...)
+}
+
+// GenerateSourceWithOptions is like `GenerateWithOptions`, but loads the
+// package at `pkgPath` from source and generates the copy function for the
+// type named `typeName` in it.
+// Ignored types must be given with `IgnoreTypeNames`.
+func GenerateSourceWithOptions(pkgPath, typeName string, opts ...Option) (importsBuf []byte, copyFnBuf []byte, err error) {
+	pkg, err := LoadPackage(pkgPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkg.GenerateWithOptions(typeName, opts...)
+}
+
+// Package is a package which has been loaded from source.
+// Loading a package type checks it along with all of its dependencies, so when
+// generating copy functions for multiple types in the same package it is best
+// to load the package once and call `Generate` for each type.
+type Package struct {
+	pkg *types.Package
+	// fset and files are the parsed files the package was loaded from
+	fset  *token.FileSet
+	files []*ast.File
+
+	// types is the list of types marked with a `// +deepcopy` comment
+	types []string
+	// fields holds the value of `// +deepcopy:<value>` comments on struct
+	// fields in the package
+	fields map[*types.Var]string
+	// names are the names packages are imported with by the code generated
+	// for the package, which are shared by all of its types so that their
+	// code can be written to the same file
+	names *importNames
+	// missing are the uses of methods which do not exist, which are only
+	// errors if the methods are not generated, see `checkMissing`
+	missing []missingMethod
+}
+
+// missingMethod is a use of a method which was not found when type checking
+// the package, such as one which was declared in an excluded file.
+type missingMethod struct {
+	err error
+	// name is the name of the method, and recv the type it was looked up on
+	name string
+	recv types.Type
+}
+
+// LoadPackage finds the package for the passed in import path (or directory)
+// and type checks it from source.
+// The files named in `exclude`, such as the output of a previous run of the
+// generator, are left out, so that stale (or broken) generated code has no
+// effect on the package. Uses of the methods which were declared in them, by
+// the rest of the package, are only errors if those methods are not generated
+// along with the types they are used on: see `Package.GenerateFile`.
+func LoadPackage(path string, exclude ...string) (*Package, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	var bp *build.Package
+	if filepath.IsAbs(path) {
+		bp, err = build.ImportDir(path, 0)
+	} else {
+		bp, err = build.Import(path, wd, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		if isExcluded(name, exclude) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(bp.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	// the methods declared in the excluded files may not exist yet either
+	return checkPackage(bp.ImportPath, fset, files, len(exclude) > 0)
+}
+
+// missingMethodMsg matches the errors for values used as interfaces which
+// their types do not implement, e.g. in `var _ Copier = Foo{}`.
+var missingMethodMsg = regexp.MustCompile(`\(missing method (\w+)\)`)
+
+// missingMethods returns the uses of methods which do not exist for the type
+// checking errors `errs`, which are expected when the generated code was left
+// out of the package. The errors are reported either at the name of the
+// method in a selector, such as `Copy` in `n.Copy()`, or at a value which does
+// not implement an interface because of the missing method. The first error
+// which is neither is returned.
+func missingMethods(errs []error, files []*ast.File, info *types.Info) ([]missingMethod, error) {
+	selectors := make(map[token.Pos]*ast.SelectorExpr)
+	// exprs holds the outermost expression at each position
+	exprs := make(map[token.Pos]ast.Expr)
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				selectors[sel.Sel.Pos()] = sel
+			}
+			if e, ok := n.(ast.Expr); ok && exprs[e.Pos()] == nil {
+				exprs[e.Pos()] = e
+			}
+			return true
+		})
+	}
+
+	var missing []missingMethod
+	for _, err := range errs {
+		terr, ok := err.(types.Error)
+		if !ok {
+			return nil, err
+		}
+		if sel, ok := selectors[terr.Pos]; ok && info.Types[sel.X].Type != nil {
+			missing = append(missing, missingMethod{err: err, name: sel.Sel.Name, recv: info.Types[sel.X].Type})
+			continue
+		}
+		m := missingMethodMsg.FindStringSubmatch(terr.Msg)
+		if e, ok := exprs[terr.Pos]; ok && m != nil && info.Types[e].Type != nil {
+			missing = append(missing, missingMethod{err: err, name: m[1], recv: info.Types[e].Type})
+			continue
+		}
+		return nil, err
+	}
+	return missing, nil
+}
+
+// checkMissing returns the error for the first use of a method which does not
+// exist, unless it is one of the methods generated with `opt` for the types
+// named in `typeNames`.
+func (p *Package) checkMissing(typeNames []string, opt options) error {
+	methods := map[string]bool{opt.methodName: true}
+	if opt.kubernetes {
+		methods = map[string]bool{"DeepCopy": true, "DeepCopyInto": true}
+		if opt.objectType != "" {
+			methods["DeepCopyObject"] = true
+		}
+	}
+	generated := make(map[string]bool, len(typeNames))
+	for _, name := range typeNames {
+		generated[name] = true
+	}
+
+	for _, m := range p.missing {
+		t := m.recv
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		named, ok := t.(*types.Named)
+		if !ok || !methods[m.name] || named.Obj().Pkg() != p.pkg || !generated[named.Origin().Obj().Name()] {
+			return m.err
+		}
+	}
+	return nil
+}
+
+// isExcluded determines if the file `name` is one of `exclude`.
+func isExcluded(name string, exclude []string) bool {
+	for _, e := range exclude {
+		if filepath.Base(e) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the name of the package, as used in the package clause.
+func (p *Package) Name() string {
+	return p.pkg.Name()
+}
+
+// Path returns the import path of the package.
+func (p *Package) Path() string {
+	return p.pkg.Path()
+}
+
+// Generate generates a copy function for the type named `typeName` in the
+// package.
+// See `GenerateSource` for details on the arguments and return values.
+func (p *Package) Generate(ref, typeName string, ignore []string, opts ...Option) (importsBuf []byte, copyFnBuf []byte, err error) {
+	return p.GenerateWithOptions(typeName, append([]Option{Receiver(ref), IgnoreTypeNames(ignore...)}, opts...)...)
+}
+
+// GenerateWithOptions generates a copy function for the type named `typeName`
+// in the package, configured by `opts`.
+// See `GenerateSourceWithOptions`.
+func (p *Package) GenerateWithOptions(typeName string, opts ...Option) (importsBuf []byte, copyFnBuf []byte, err error) {
+	opt := newOptions(opts)
+	if err := p.checkMissing([]string{typeName}, opt); err != nil {
+		return nil, nil, err
+	}
+	return p.generate(typeName, opt)
+}
+
+// generate generates the copy function for the type named `typeName`, without
+// checking the uses of missing methods.
+func (p *Package) generate(typeName string, opt options) (importsBuf []byte, copyFnBuf []byte, err error) {
+	obj, ok := p.pkg.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil, nil, fmt.Errorf("type %s not found in package %s", typeName, p.pkg.Path())
+	}
+
+	t, err := instantiate(obj.Type())
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, isPtr := t.Underlying().(*types.Pointer); opt.pointerReceiver && !isPtr {
+		t = types.NewPointer(t)
+	}
+	return generateCopy(p.typeOf(t), opt, p.names)
+}
+
+func (p *Package) typeOf(t types.Type) goType {
+	return sourceType{t: t, pkg: p}
+}
+
+// checkPackage type checks the passed in files as the package at `path`.
+// Imported packages are type checked from source as well so that no compiled
+// packages are required.
+// If `excluded` is set the generated files of the package were left out, so
+// uses of methods which do not exist are ignored, see `missingMethods`.
+func checkPackage(path string, fset *token.FileSet, files []*ast.File, excluded bool) (*Package, error) {
+	var errs []error
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(err error) { errs = append(errs, err) },
+	}
+	info := &types.Info{Defs: make(map[*ast.Ident]types.Object), Types: make(map[ast.Expr]types.TypeAndValue)}
+	pkg, _ := conf.Check(path, fset, files, info)
+	var missing []missingMethod
+	if len(errs) > 0 {
+		if !excluded {
+			return nil, errs[0]
+		}
+		var err error
+		if missing, err = missingMethods(errs, files, info); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &Package{pkg: pkg, fset: fset, files: files, names: newImportNames(pkg.Scope().Names()...), missing: missing}
+	p.types, p.fields = parseMarkers(files, info)
+	return p, nil
+}
+
+// Check type checks the package with `src`, such as the code generated for it,
+// added as the file `name`, and returns the first error found. Unlike when
+// loading the package, uses of methods which do not exist are errors.
+func (p *Package) Check(name string, src []byte) error {
+	f, err := parser.ParseFile(p.fset, name, src, 0)
+	if err != nil {
+		return err
+	}
+	conf := types.Config{Importer: importer.ForCompiler(p.fset, "source", nil)}
+	_, err = conf.Check(p.pkg.Path(), p.fset, append(p.files[:len(p.files):len(p.files)], f), nil)
+	return err
+}
+
+// sourceType implements goType for a types.Type
+type sourceType struct {
+	t types.Type
+	// pkg is the package the type was loaded from
+	pkg *Package
+}
+
+// wrap converts a type reached from this one into a goType
+func (t sourceType) wrap(tt types.Type) goType {
+	if tt == nil {
+		return nil
+	}
+	return sourceType{t: tt, pkg: t.pkg}
+}
+
+var basicKinds = map[types.BasicKind]reflect.Kind{
+	types.Bool:          reflect.Bool,
+	types.Int:           reflect.Int,
+	types.Int8:          reflect.Int8,
+	types.Int16:         reflect.Int16,
+	types.Int32:         reflect.Int32,
+	types.Int64:         reflect.Int64,
+	types.Uint:          reflect.Uint,
+	types.Uint8:         reflect.Uint8,
+	types.Uint16:        reflect.Uint16,
+	types.Uint32:        reflect.Uint32,
+	types.Uint64:        reflect.Uint64,
+	types.Uintptr:       reflect.Uintptr,
+	types.Float32:       reflect.Float32,
+	types.Float64:       reflect.Float64,
+	types.Complex64:     reflect.Complex64,
+	types.Complex128:    reflect.Complex128,
+	types.String:        reflect.String,
+	types.UnsafePointer: reflect.UnsafePointer,
+}
+
+func (t sourceType) Kind() reflect.Kind {
+	switch u := t.t.Underlying().(type) {
+	case *types.Basic:
+		return basicKinds[u.Kind()]
+	case *types.Pointer:
+		return reflect.Ptr
+	case *types.Slice:
+		return reflect.Slice
+	case *types.Array:
+		return reflect.Array
+	case *types.Map:
+		return reflect.Map
+	case *types.Chan:
+		return reflect.Chan
+	case *types.Struct:
+		return reflect.Struct
+	case *types.Interface:
+		return reflect.Interface
+	case *types.Signature:
+		return reflect.Func
+	}
+	return reflect.Invalid
+}
+
+func (t sourceType) Name() string {
+	switch tt := t.t.(type) {
+	case *types.Named:
+		return tt.Obj().Name()
+	case *types.Alias:
+		// aliases, such as `json.RawMessage`, are written by their own name
+		return tt.Obj().Name()
+	case *types.Basic:
+		// match reflect, which uses the canonical name for aliases like `byte`
+		return types.Typ[tt.Kind()].Name()
+	}
+	return ""
+}
+
+func (t sourceType) PkgPath() string {
+	switch tt := t.t.(type) {
+	case *types.Named:
+		if tt.Obj().Pkg() != nil {
+			return tt.Obj().Pkg().Path()
+		}
+	case *types.Alias:
+		if tt.Obj().Pkg() != nil {
+			return tt.Obj().Pkg().Path()
+		}
+	}
+	return ""
+}
+
+func (t sourceType) PkgName() string {
+	switch tt := t.t.(type) {
+	case *types.Named:
+		if tt.Obj().Pkg() != nil {
+			return tt.Obj().Pkg().Name()
+		}
+	case *types.Alias:
+		if tt.Obj().Pkg() != nil {
+			return tt.Obj().Pkg().Name()
+		}
+	}
+	return ""
+}
+
+// String returns the type qualified by package name, the same as reflect.
+func (t sourceType) String() string {
+	return types.TypeString(t.t, func(p *types.Package) string {
+		return p.Name()
+	})
+}
+
+func (t sourceType) Elem() goType {
+	switch u := t.t.Underlying().(type) {
+	case *types.Pointer:
+		return t.wrap(u.Elem())
+	case *types.Slice:
+		return t.wrap(u.Elem())
+	case *types.Array:
+		return t.wrap(u.Elem())
+	case *types.Map:
+		return t.wrap(u.Elem())
+	case *types.Chan:
+		return t.wrap(u.Elem())
+	}
+	panic("deepcopy: Elem of invalid type " + t.String())
+}
+
+func (t sourceType) ChanDir() reflect.ChanDir {
+	if c, ok := t.t.Underlying().(*types.Chan); ok {
+		switch c.Dir() {
+		case types.SendOnly:
+			return reflect.SendDir
+		case types.RecvOnly:
+			return reflect.RecvDir
+		}
+		return reflect.BothDir
+	}
+	panic("deepcopy: ChanDir of non-chan type " + t.String())
+}
+
+func (t sourceType) PtrTo() goType {
+	return t.wrap(types.NewPointer(t.t))
+}
+
+func (t sourceType) Key() goType {
+	if m, ok := t.t.Underlying().(*types.Map); ok {
+		return t.wrap(m.Key())
+	}
+	panic("deepcopy: Key of non-map type " + t.String())
+}
+
+func (t sourceType) Len() int {
+	if a, ok := t.t.Underlying().(*types.Array); ok {
+		return int(a.Len())
+	}
+	panic("deepcopy: Len of non-array type " + t.String())
+}
+
+func (t sourceType) NumField() int {
+	if s, ok := t.t.Underlying().(*types.Struct); ok {
+		return s.NumFields()
+	}
+	panic("deepcopy: NumField of non-struct type " + t.String())
+}
+
+func (t sourceType) Field(i int) structField {
+	s, ok := t.t.Underlying().(*types.Struct)
+	if !ok {
+		panic("deepcopy: Field of non-struct type " + t.String())
+	}
+	f := s.Field(i)
+	tag := reflect.StructTag(s.Tag(i))
+	directive, ok := tag.Lookup("deepcopy")
+	if !ok {
+		directive = t.pkg.fields[f]
+	}
+	return structField{
+		Name:      f.Name(),
+		Type:      t.wrap(f.Type()),
+		Tag:       tag,
+		Anonymous: f.Embedded(),
+		Directive: directive,
+	}
+}
+
+func (t sourceType) MethodByName(name string) (method, bool) {
+	tt := t.t
+	if tp, ok := tt.(*types.TypeParam); ok {
+		// the methods of a type parameter are those of its constraint
+		tt = tp.Constraint()
+	}
+	sel := types.NewMethodSet(tt).Lookup(nil, name)
+	if sel == nil {
+		return method{}, false
+	}
+	sig := sel.Type().(*types.Signature)
+
+	out := method{Name: name, Promoted: len(sel.Index()) > 1}
+	for i := 0; i < sig.Params().Len(); i++ {
+		out.In = append(out.In, t.wrap(sig.Params().At(i).Type()))
+	}
+	for i := 0; i < sig.Results().Len(); i++ {
+		out.Out = append(out.Out, t.wrap(sig.Results().At(i).Type()))
+	}
+	return out, true
+}
+
+func (t sourceType) TypeArgs() []goType {
+	named, ok := t.t.(*types.Named)
+	if !ok {
+		return nil
+	}
+	var out []goType
+	for i := 0; i < named.TypeArgs().Len(); i++ {
+		out = append(out, t.wrap(named.TypeArgs().At(i)))
+	}
+	return out
+}
+
+func (t sourceType) Identical(other goType) bool {
+	o, ok := other.(sourceType)
+	return ok && types.Identical(o.t, t.t)
+}
+
+// Implementers returns the types declared in the package being generated for
+// which implement the interface type `t`, sorted by name.
+// If only the pointer to a type implements the interface, the pointer type is
+// returned, otherwise both the type and the pointer type are.
+func (t sourceType) Implementers() []goType {
+	iface, ok := t.t.Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+
+	var out []goType
+	scope := t.pkg.pkg.Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || obj.IsAlias() || types.IsInterface(obj.Type()) {
+			continue
+		}
+		if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+			continue
+		}
+		if types.Implements(obj.Type(), iface) {
+			out = append(out, t.wrap(obj.Type()))
+		}
+		if ptr := types.NewPointer(obj.Type()); types.Implements(ptr, iface) {
+			out = append(out, t.wrap(ptr))
+		}
+	}
+	return out
+}
@@ -0,0 +1,298 @@
+package deepcopy
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sync"
+	"testing"
+)
+
+var fixturesPkg struct {
+	once sync.Once
+	pkg  *Package
+	err  error
+}
+
+// loadFixtures type checks the fixtures used by the tests in this package so
+// they can be used for generation from source.
+// Type checking the standard library packages they import from source is
+// slow, so they are only loaded once.
+func loadFixtures(t *testing.T) *Package {
+	fixturesPkg.once.Do(func() {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "fixtures_test.go", nil, parser.ParseComments)
+		if err != nil {
+			fixturesPkg.err = err
+			return
+		}
+		fixturesPkg.pkg, fixturesPkg.err = checkPackage("github.com/cpuguy83/go-generate/deepcopy", fset, []*ast.File{f}, false)
+	})
+	if fixturesPkg.err != nil {
+		t.Fatal(fixturesPkg.err)
+	}
+	return fixturesPkg.pkg
+}
+
+func TestGenerateSource(t *testing.T) {
+	type run struct {
+		explain  string
+		typeName string
+		x        []byte
+		err      error
+		ignore   []string
+	}
+	cases := []run{
+		{"A string type", "stringType", stringTypeX, nil, nil},
+		{"An array type", "arrayType", arrayTypeX, nil, nil},
+		{"An array of arrays type", "arrayOfArray", arrayOfArrayX, nil, nil},
+		{"A simple slice type", "sliceType", sliceTypeX, nil, nil},
+		{"A 2-D slice type", "doubleSliceType", doubleSliceTypeX, nil, nil},
+		{"A 2-D slice with struct ptr", "doubleSliceWithStructPtr", doubleSliceWithStructPtrX, nil, nil},
+		{"A simple map type", "mapType", mapTypeX, nil, nil},
+		{"A map of slices", "mapOfSlices", mapOfSlicesX, nil, nil},
+		{"A map of maps", "mapOfMaps", mapOfMapsX, nil, nil},
+		{"A map of arrays", "mapOfArrays", mapOfArraysX, nil, nil},
+		{"A struct with arrays in other types", "structWithArrays", structWithArraysX, nil, nil},
+		{"A struct with imported arrays in other types", "structWithImportedArrays", structWithImportedArraysX, nil, nil},
+		{"A simple struct", "simpleStruct", simpleStructX, nil, nil},
+		{"A struct with an embedded struct pointer", "structWithEmbeddedPointer", structWithEmbeddedPointerX, nil, nil},
+		{"A complex struct with mixed reference types", "complexStruct", complexStructX, nil, nil},
+		{"A struct which imports from another package", "structWithImports", structWithImportsX, nil, nil},
+		{"A struct with imports that are unexported in another pkg", "structWithUnexportedImportTypes", nil, ErrUnexportedType, nil},
+		{"A struct which imports from another package with unexported but simple fields", "structWithImportsAndSimpleFields", structWithImportsAndSimpleFieldsX, nil, nil},
+		{"A struct which imports from another package with unsettable fields", "structWithImportsAndUnsettableFields", nil, ErrUnsettableField, nil},
+		{"A struct which imports from another package with unsettable fields that are ignored", "structWithImportsAndUnsettableFields", structWithImportsAndUnsettableFieldsX, nil, []string{"github.com/cpuguy83/go-generate/deepcopy/fixtures.Banana"}},
+		{"A struct which implements DeepCopy", "structWithDeepCopy", structWithDeepCopyX, nil, nil},
+		{"A struct with an imported ptr struct which implements DeepCopy", "structPtrWithCopyMethod", structPtrWithCopyMethodX, nil, nil},
+		{"A struct with an imported struct which implements DeepCopy", "structWithCopyMethod", structWithCopyMethodX, nil, nil},
+		{"A struct with an imported struct that does not require an import statement", "structWithImportButNotNeeded", structWithImportButNotNeededX, nil, nil},
+		{"A struct with an imported struct in a map that needs an import statement", "structWithImportNeededMap", structWithImportNeededMapX, nil, nil},
+		{"A struct with an imported struct in a slice that needs an import statement", "structWithImportNeededSlice", structWithImportNeededSliceX, nil, nil},
+		{"A struct that uses an imported custom slice type", "structWithImportedCustomSliceType", structWithImportedCustomSliceTypeX, nil, nil},
+		{"A struct type with a channel", "structWithChannel", nil, ErrUnsupportedType, nil},
+		{"A struct with a skipped field", "structWithSkip", structWithSkipX, nil, nil},
+		{"A struct with a field skipped by a marker comment", "structWithSkipMarker", structWithSkipMarkerX, nil, nil},
+		{"A struct with standard library types", "structWithStdlibTypes", structWithStdlibTypesX, nil, nil},
+		{"A struct with locks", "structWithLocks", structWithLocksX, nil, nil},
+		{"A struct with locks in a slice", "structWithLocksInSlice", nil, ErrUnsupportedType, nil},
+		{"A struct with deepcopy tags", "structWithTags", structWithTagsX, nil, nil},
+		{"A struct with zero tags on imported types", "structWithZeroTagsOnImports", structWithZeroTagsOnImportsX, nil, nil},
+		{"A struct with deepcopy marker comments", "structWithTagMarkers", structWithTagMarkersX, nil, nil},
+		{"A struct with an unknown deepcopy tag", "structWithUnknownTag", nil, ErrInvalidTag, nil},
+		{"A struct with a policy deepcopy tag on a field without channels", "structWithPolicyTagOnValue", nil, ErrInvalidTag, nil},
+		{"A recursive struct", "linkedList", linkedListX, nil, nil},
+		{"A recursive map", "nestedMap", nestedMapX, nil, nil},
+		{"A struct with a recursive field type", "tree", treeX, nil, nil},
+		{"A struct with a value and pointer receiver Copy methods", "structWithPtrReceiverCopy", structWithPtrReceiverCopyX, nil, nil},
+		{"A struct with an embedded field which has a Copy method", "embedsCopier", embedsCopierX, nil, nil},
+		{"A struct with a Clone method", "structWithCloner", structWithClonerX, nil, nil},
+		{"A struct with a Copy method with the wrong signature", "structWithBadCopier", nil, ErrCopyMethod, nil},
+		{"A struct with interface fields", "structWithInterfaces", structWithInterfacesSourceX, nil, nil},
+		{"A generic map type", "genericSet", genericSetX, nil, nil},
+		{"A generic struct with a recursive field type", "genericTree", genericTreeX, nil, nil},
+		{"A generic struct with a nil tag on a type parameter", "structWithNilTagOnTypeParam", nil, ErrInvalidTag, nil},
+	}
+
+	pkg := loadFixtures(t)
+	for _, c := range cases {
+		t.Run(c.explain, func(t *testing.T) {
+			imports, copyFunc, err := pkg.Generate("o", c.typeName, c.ignore)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("%s: expected '%v', got: %v", c.explain, c.err, err)
+			}
+
+			actual, err := format.Source(append(imports, copyFunc...))
+			if err != nil {
+				t.Fatal(err.Error() + "\n" + string(copyFunc))
+			}
+
+			xFmt, err := format.Source(c.x)
+			if err != nil {
+				t.Fatalf("%s: %v\n\n%s", c.explain, err, string(c.x))
+			}
+			if !bytes.Equal(bytes.TrimSpace(actual), bytes.TrimSpace(xFmt)) {
+				t.Fatalf("%s: expected: \n%s\n\ngot: \n%s\n\n", c.explain, string(xFmt), string(actual))
+			}
+		})
+	}
+}
+
+func TestGenerateSourceTypeNotFound(t *testing.T) {
+	pkg := loadFixtures(t)
+	if _, _, err := pkg.Generate("o", "doesNotExist", nil); err == nil {
+		t.Fatal("expected error for missing type")
+	}
+}
+
+func TestPackageCheck(t *testing.T) {
+	pkg := loadFixtures(t)
+	f, err := pkg.GenerateFile([]string{"complexStruct"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pkg.Check("zz_deepcopy.go", src); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := []byte("package deepcopy\n\nfunc (o complexStruct) Copy() complexStruct {\n\treturn o.B\n}\n")
+	if err := pkg.Check("zz_deepcopy.go", broken); err == nil {
+		t.Fatal("expected an error for code which does not compile")
+	}
+}
+
+func TestMarkedTypes(t *testing.T) {
+	pkg := loadFixtures(t)
+	marked := pkg.MarkedTypes()
+	if len(marked) != 1 || marked[0] != "structWithSkipMarker" {
+		t.Fatalf("unexpected marked types: %v", marked)
+	}
+}
+
+func TestGenerateSourcePreserveAliasing(t *testing.T) {
+	type run struct {
+		explain  string
+		typeName string
+		x        []byte
+	}
+	cases := []run{
+		{"A recursive struct", "linkedList", linkedListAliasingX},
+		{"A recursive map", "nestedMap", nestedMapAliasingX},
+		{"A struct with pointers, maps and slices", "sharedRefs", sharedRefsAliasingX},
+	}
+
+	pkg := loadFixtures(t)
+	for _, c := range cases {
+		t.Run(c.explain, func(t *testing.T) {
+			imports, copyFunc, err := pkg.Generate("o", c.typeName, nil, PreserveAliasing())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := format.Source(append(imports, copyFunc...))
+			if err != nil {
+				t.Fatal(err.Error() + "\n" + string(copyFunc))
+			}
+
+			xFmt, err := format.Source(c.x)
+			if err != nil {
+				t.Fatalf("%s: %v\n\n%s", c.explain, err, string(c.x))
+			}
+			if !bytes.Equal(bytes.TrimSpace(actual), bytes.TrimSpace(xFmt)) {
+				t.Fatalf("%s: expected: \n%s\n\ngot: \n%s\n\n", c.explain, string(xFmt), string(actual))
+			}
+		})
+	}
+}
+
+func TestGenerateSourceInterfaceFallback(t *testing.T) {
+	type run struct {
+		explain  string
+		typeName string
+		policy   Policy
+		x        []byte
+		err      error
+	}
+	cases := []run{
+		{"Unknown types are set to nil", "shapeHolder", PolicyNil, shapeHolderNilX, nil},
+		{"Unknown types panic", "shapeHolder", PolicyPanic, shapeHolderPanicX, nil},
+		{"Interfaces are an error", "shapeHolder", PolicyError, nil, ErrUnsupportedType},
+		{"Interfaces with a Copy method are not an error", "copierShapeHolder", PolicyError, copierShapeHolderX, nil},
+	}
+
+	pkg := loadFixtures(t)
+	for _, c := range cases {
+		t.Run(c.explain, func(t *testing.T) {
+			imports, copyFunc, err := pkg.Generate("o", c.typeName, nil, InterfaceFallback(c.policy))
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("expected '%v', got: %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := format.Source(append(imports, copyFunc...))
+			if err != nil {
+				t.Fatal(err.Error() + "\n" + string(copyFunc))
+			}
+
+			xFmt, err := format.Source(c.x)
+			if err != nil {
+				t.Fatalf("%s: %v\n\n%s", c.explain, err, string(c.x))
+			}
+			if !bytes.Equal(bytes.TrimSpace(actual), bytes.TrimSpace(xFmt)) {
+				t.Fatalf("%s: expected: \n%s\n\ngot: \n%s\n\n", c.explain, string(xFmt), string(actual))
+			}
+		})
+	}
+}
+
+func TestGenerateSourceRefPolicies(t *testing.T) {
+	type run struct {
+		explain string
+		opts    []Option
+		x       []byte
+		err     error
+	}
+	cases := []run{
+		{"Channels are an error by default", nil, nil, ErrUnsupportedType},
+		{"Channels are shared", []Option{ChanPolicy(PolicyShare)}, workerShareX, nil},
+		{"Fresh channels and nil funcs", []Option{ChanPolicy(PolicyFresh), FuncPolicy(PolicyNil)}, workerFreshX, nil},
+		{"Funcs cannot be fresh", []Option{ChanPolicy(PolicyShare), FuncPolicy(PolicyFresh)}, nil, ErrUnsupportedType},
+	}
+
+	pkg := loadFixtures(t)
+	for _, c := range cases {
+		t.Run(c.explain, func(t *testing.T) {
+			imports, copyFunc, err := pkg.Generate("o", "worker", nil, c.opts...)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("%s: expected '%v', got: %v", c.explain, c.err, err)
+			}
+
+			actual, err := format.Source(append(imports, copyFunc...))
+			if err != nil {
+				t.Fatal(err.Error() + "\n" + string(copyFunc))
+			}
+
+			xFmt, err := format.Source(c.x)
+			if err != nil {
+				t.Fatalf("%s: %v\n\n%s", c.explain, err, string(c.x))
+			}
+			if !bytes.Equal(bytes.TrimSpace(actual), bytes.TrimSpace(xFmt)) {
+				t.Fatalf("%s: expected: \n%s\n\ngot: \n%s\n\n", c.explain, string(xFmt), string(actual))
+			}
+		})
+	}
+}
+
+func TestGenerateSourceWithOptions(t *testing.T) {
+	pkg := loadFixtures(t)
+	imports, copyFunc, err := pkg.GenerateWithOptions("structWithImports", Receiver("s"), MethodName("Clone"), PointerReceiver(), FormatOutput())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := append(imports, copyFunc...); !bytes.Equal(actual, structWithImportsCloneX) {
+		t.Fatalf("expected: \n%s\n\ngot: \n%s\n\n", structWithImportsCloneX, actual)
+	}
+}
+
+func TestGenerateSourceKubernetesStyle(t *testing.T) {
+	pkg := loadFixtures(t)
+	imports, copyFunc, err := pkg.GenerateWithOptions("kubeObject", Receiver("in"), DeepCopyObject("Object", "k8s.io/apimachinery/pkg/runtime"), FormatOutput())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := append(imports, copyFunc...); !bytes.Equal(actual, kubeObjectX) {
+		t.Fatalf("expected: \n%s\n\ngot: \n%s\n\n", kubeObjectX, actual)
+	}
+}
@@ -0,0 +1,123 @@
+package deepcopy
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"strings"
+)
+
+// directiveKind is what a `deepcopy` struct tag tells the generator to do with
+// a field.
+type directiveKind int
+
+const (
+	// directiveDeep copies the field as usual.
+	// This is also used for the channel and func policies, which are applied
+	// by `policyFor`.
+	directiveDeep directiveKind = iota
+	// directiveShallow assigns the field without recursing into it
+	// (`deepcopy:"shallow"` or `deepcopy:"skip"`).
+	directiveShallow
+	// directiveZero leaves the zero value in the copy (`deepcopy:"zero"`).
+	directiveZero
+	// directiveNil sets a reference field to nil in the copy
+	// (`deepcopy:"nil"`).
+	directiveNil
+	// directiveFunc calls a user function to copy the field
+	// (`deepcopy:"func=cloneThing"`).
+	directiveFunc
+	// directiveGuard marks a lock which is held on the original while it is
+	// being copied (`deepcopy:"guard"`).
+	directiveGuard
+)
+
+// directive is a parsed `deepcopy` struct tag.
+type directive struct {
+	kind directiveKind
+	// fn is the name of the function for directiveFunc
+	fn string
+}
+
+// parseDirective parses the value of a `deepcopy` struct tag (or field marker).
+//
+// The accepted values are:
+//   - `skip` or `shallow` to assign the field without making a deep copy
+//   - `zero` to leave the field as the zero value in the copy
+//   - `nil` to set a pointer, map, slice, channel, func or interface field to
+//     nil in the copy
+//   - `func=<name>` to copy the field by calling the function `<name>`, which
+//     must be in the package of the type being generated and take and return
+//     the type of the field
+//   - `guard` on a `sync.Mutex` or `sync.RWMutex` field to hold the lock on
+//     the original while copying the struct
+//   - the name of a `Policy` for channel and func values in the field, which
+//     is only valid on a field of type `t` which holds them (see
+//     `holdsChanOrFunc`)
+//
+// On a field holding channels or funcs in a pointer, slice, array or map `nil`
+// is the policy, so that they are set to nil rather than the field itself.
+func parseDirective(s string, t goType) (directive, error) {
+	switch s {
+	case "":
+		return directive{kind: directiveDeep}, nil
+	case "skip", "shallow":
+		return directive{kind: directiveShallow}, nil
+	case "zero":
+		return directive{kind: directiveZero}, nil
+	case "nil":
+		if k := t.Kind(); k != reflect.Chan && k != reflect.Func && holdsChanOrFunc(t) {
+			return directive{kind: directiveDeep}, nil
+		}
+		return directive{kind: directiveNil}, nil
+	case "guard":
+		return directive{kind: directiveGuard}, nil
+	}
+	if fn := strings.TrimPrefix(s, "func="); fn != s {
+		if !token.IsIdentifier(fn) {
+			return directive{}, wrapErr(ErrInvalidTag, fmt.Sprintf("invalid function name in deepcopy tag %q", s))
+		}
+		return directive{kind: directiveFunc, fn: fn}, nil
+	}
+	if _, err := ParsePolicy(s); err == nil {
+		if !holdsChanOrFunc(t) {
+			return directive{}, wrapErr(ErrInvalidTag, fmt.Sprintf("deepcopy tag %q used on %s field which holds no channels or funcs", s, t.Kind()))
+		}
+		return directive{kind: directiveDeep}, nil
+	}
+	return directive{}, wrapErr(ErrInvalidTag, fmt.Sprintf("unknown deepcopy tag %q", s))
+}
+
+// holdsChanOrFunc determines if values of type `t` are channels or funcs, or
+// hold them in pointers, slices, arrays or maps, which are copied according
+// to the policy set on the struct field holding them.
+func holdsChanOrFunc(t goType) bool {
+	for {
+		switch t.Kind() {
+		case reflect.Chan, reflect.Func:
+			return true
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+}
+
+// zeroValue returns the expression for the zero value of the type `t`.
+func (g *generator) zeroValue(t goType) string {
+	if isTypeParam(t) {
+		return "*new(" + g.getName(t) + ")"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "false"
+	case reflect.String:
+		return `""`
+	case reflect.Struct, reflect.Array:
+		return g.getName(t) + "{}"
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func, reflect.Interface, reflect.UnsafePointer:
+		return "nil"
+	}
+	return "0"
+}
@@ -0,0 +1,339 @@
+package deepcopy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// testRoot is a type to generate a test for.
+type testRoot struct {
+	// name is the name of the type, which is also the root of the paths in
+	// its errors
+	name string
+	t    goType
+}
+
+// GenerateTestFile generates a test for the copy method of each of the passed
+// in objects, as generated by `GenerateFile`, into a single file, which is
+// meant to be written next to it with a `_test.go` suffix.
+//
+// Each test fills in a value of the type with non-zero data, following every
+// map, slice and pointer, and checks that its copy is equal to it. It then
+// changes every value in the copy, and checks that the original is unchanged.
+// Values which are not meant to be deep copied, such as fields tagged with
+// `shallow` or types copied by a registered copier, are left alone, and
+// interfaces, channels and funcs are left as nil.
+//
+// It is the same as calling `GenerateTestFileWithOptions(pkg, objs)`.
+func GenerateTestFile(pkg string, objs ...interface{}) ([]byte, error) {
+	return GenerateTestFileWithOptions(pkg, objs)
+}
+
+// GenerateTestFileWithOptions is like `GenerateTestFile`, for the code
+// generated with `opts`.
+// With the `BestEffort` option the fields which cannot be copied are left
+// alone, and the errors for them are returned along with the file.
+func GenerateTestFileWithOptions(pkg string, objs []interface{}, opts ...Option) ([]byte, error) {
+	src, genErr := GenerateFileWithOptions(pkg, objs, opts...)
+	if src == nil {
+		return nil, genErr
+	}
+
+	opt := newOptions(opts)
+	rootTypes, err := fileRootTypes(objs, opt)
+	if err != nil {
+		return nil, err
+	}
+	var roots []testRoot
+	for _, t := range rootTypes {
+		t = baseType(t)
+		if t.Name() == "" {
+			// no method can be declared on the type
+			continue
+		}
+		roots = append(roots, testRoot{name: t.Name(), t: fromReflect(t)})
+	}
+
+	f, err := newTestFile(pkg, roots, genErr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if src, err = f.Bytes(); err != nil {
+		return nil, err
+	}
+	return src, genErr
+}
+
+// GenerateTestFile generates a test for the copy method of each of the types
+// named in `typeNames`, or of the marked types if there are none, as generated
+// by `GenerateFile` with the same options.
+// Generic types are not tested, since the tests would need type arguments for
+// them.
+// See `GenerateTestFileWithOptions` for details.
+func (p *Package) GenerateTestFile(typeNames []string, opts ...Option) (*File, error) {
+	typeNames, err := p.fileTypeNames(typeNames)
+	if err != nil {
+		return nil, err
+	}
+	f, genErr := p.GenerateFile(typeNames, opts...)
+	if f == nil {
+		return nil, genErr
+	}
+
+	var roots []testRoot
+	for _, name := range typeNames {
+		obj := p.pkg.Scope().Lookup(name).(*types.TypeName)
+		if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+			continue
+		}
+		roots = append(roots, testRoot{name: name, t: p.typeOf(obj.Type())})
+	}
+	f, err = newTestFile(p.Name(), roots, genErr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return f, genErr
+}
+
+// newTestFile returns the test file for `roots` in the package `pkgName`.
+// `genErr` is the error returned when generating their code, which is how the
+// fields which cannot be copied are found, so the code is generated first.
+func newTestFile(pkgName string, roots []testRoot, genErr error, opts ...Option) (*File, error) {
+	opt := newOptions(opts)
+	method := opt.methodName
+	if opt.kubernetes {
+		method = "DeepCopy"
+	}
+
+	prefix := opt.testHelperPrefix
+	rules := make(map[string]string)
+	seen := make(map[string]bool)
+	buf := bytes.NewBuffer(nil)
+	for _, root := range roots {
+		addTestRules(rules, root.t, opt, seen)
+		fmt.Fprintf(buf, "\nfunc Test%s_%s(t *testing.T) {\n", method, root.name)
+		fmt.Fprintf(buf, "var want, o %s\n", root.name)
+		fmt.Fprintf(buf, "%sFill(&want, %q)\n%sFill(&o, %q)\n", prefix, root.name, prefix, root.name)
+		fmt.Fprintf(buf, "%sCheck(t, %q, &want, &o, o.%s())\n}\n", prefix, root.name, method)
+	}
+
+	// the fields which cannot be copied are left alone
+	var errs Errors
+	var typeErr *TypeError
+	switch {
+	case errors.As(genErr, &errs):
+	case errors.As(genErr, &typeErr):
+		errs = Errors{typeErr}
+	}
+	for _, err := range errs {
+		rules[err.Path] = "zero"
+	}
+
+	keys := make([]string, 0, len(rules))
+	for k := range rules {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(buf, "\n// %sRules are the values which are not deep copied: types by type key\n", prefix)
+	buf.WriteString("// (\"opaque\"), and struct fields by the type key of the struct, or the path to\n")
+	buf.WriteString("// the field, followed by the field name (with its deepcopy tag).\n")
+	fmt.Fprintf(buf, "var %sRules = map[string]string{\n", prefix)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "%q: %q,\n", k, rules[k])
+	}
+	buf.WriteString("}\n")
+	// every name in testHelpers starting with deepcopy is a helper
+	buf.WriteString(strings.ReplaceAll(testHelpers, "deepcopy", prefix))
+
+	f := NewFile(pkgName, opts...)
+	importsBuf := bytes.NewBuffer(nil)
+	writeImports(importsBuf, map[string]string{"reflect": "reflect", "strconv": "strconv", "strings": "strings", "testing": "testing", "unsafe": "unsafe"})
+	if err := f.Add(importsBuf.Bytes(), buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// addTestRules adds the rules for the values reachable from `t` which the
+// generated code does not deep copy to `rules`, see `testHelpers`.
+func addTestRules(rules map[string]string, t goType, opt options, seen map[string]bool) {
+	key := typeKey(t)
+	if seen[key] || isTypeParam(t) {
+		return
+	}
+	seen[key] = true
+
+	_, isBuiltin := lookupBuiltin(t)
+	_, hasCopier := lookupCopier(t)
+	if isBuiltin || hasCopier || opt.ignored[key] || isSyncType(t) {
+		rules[key] = "opaque"
+		return
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		addTestRules(rules, t.Elem(), opt, seen)
+	case reflect.Map:
+		addTestRules(rules, t.Key(), opt, seen)
+		addTestRules(rules, t.Elem(), opt, seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Directive != "" && t.Name() != "" {
+				rules[key+"."+field.Name] = field.Directive
+			}
+			addTestRules(rules, field.Type, opt, seen)
+		}
+	}
+}
+
+// testHelpers are the functions used by the generated tests, named with the
+// `TestHelperPrefix` in place of `deepcopy`.
+//
+// Values are filled in, and changed, the same way: numbers are increased,
+// strings are appended to and bools are flipped. Only the references which are
+// nil are filled in, up to `deepcopyMaxDepth` references deep, so that
+// recursive types end. Past that maps and slices are left empty rather than
+// nil.
+const testHelpers = `
+// deepcopyMaxDepth is how many references deep values are filled in.
+const deepcopyMaxDepth = 3
+
+// deepcopyFill fills in the value pointed to by v, at path, with non-zero data.
+func deepcopyFill(v interface{}, path string) {
+	(&deepcopyValues{}).walk(reflect.ValueOf(v).Elem(), path, 0)
+}
+
+// deepcopyCheck checks that c, the copy of the value pointed to by orig, is
+// equal to it, then changes every value in the copy and checks that the
+// original is still equal to want.
+func deepcopyCheck(t *testing.T, path string, want, orig, c interface{}) {
+	t.Helper()
+	cv := reflect.ValueOf(c)
+	if cv.Type() != reflect.TypeOf(orig) {
+		// the copy was returned by value
+		p := reflect.New(cv.Type())
+		p.Elem().Set(cv)
+		cv = p
+	}
+	if !reflect.DeepEqual(cv.Interface(), orig) {
+		t.Fatalf("the copy of %s is not equal to the original:\n%+v\n%+v", path, cv.Elem(), reflect.ValueOf(orig).Elem())
+	}
+	(&deepcopyValues{change: true}).walk(cv.Elem(), path, 0)
+	if !reflect.DeepEqual(orig, want) {
+		t.Fatalf("changing the copy of %s changed the original:\n%+v", path, reflect.ValueOf(orig).Elem())
+	}
+}
+
+// deepcopyValues fills in values, or changes the values which are already
+// there if change is set.
+type deepcopyValues struct {
+	change bool
+	n      int
+}
+
+func (d *deepcopyValues) walk(v reflect.Value, path string, depth int) {
+	if deepcopyRules[deepcopyTypeKey(v.Type())] == "opaque" {
+		return
+	}
+	d.n++
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(!v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(v.Int() + int64(d.n%100+1))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(v.Uint() + uint64(d.n%100+1))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(v.Float() + float64(d.n) + 0.5)
+	case reflect.Complex64, reflect.Complex128:
+		v.SetComplex(v.Complex() + complex(float64(d.n), 1))
+	case reflect.String:
+		v.SetString(v.String() + strconv.Itoa(d.n))
+	case reflect.Ptr:
+		if v.IsNil() {
+			if d.change || depth >= deepcopyMaxDepth {
+				return
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		d.walk(v.Elem(), path, depth+1)
+	case reflect.Slice:
+		if v.IsNil() {
+			if d.change {
+				return
+			}
+			n := 2
+			if depth >= deepcopyMaxDepth {
+				n = 0
+			}
+			v.Set(reflect.MakeSlice(v.Type(), n, n))
+		}
+		for i := 0; i < v.Len(); i++ {
+			d.walk(v.Index(i), path+"[]", depth+1)
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			d.walk(v.Index(i), path+"[]", depth)
+		}
+	case reflect.Map:
+		t := v.Type()
+		if v.IsNil() {
+			if d.change || t.Key().Kind() == reflect.Interface {
+				return
+			}
+			v.Set(reflect.MakeMap(t))
+			for i := 0; i < 2 && depth < deepcopyMaxDepth; i++ {
+				key := reflect.New(t.Key()).Elem()
+				d.walk(key, path+"[]", depth+1)
+				elem := reflect.New(t.Elem()).Elem()
+				d.walk(elem, path+"[]", depth+1)
+				v.SetMapIndex(key, elem)
+			}
+			return
+		}
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(t.Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			d.walk(elem, path+"[]", depth+1)
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			name := t.Field(i).Name
+			rule, ok := deepcopyRules[deepcopyTypeKey(t)+"."+name]
+			if !ok {
+				rule = deepcopyRules[path+"."+name]
+			}
+			switch {
+			case rule == "zero" || rule == "nil":
+				continue
+			case d.change && (rule == "skip" || rule == "shallow" || strings.HasPrefix(rule, "func=")):
+				// shared with the original
+				continue
+			}
+			f := v.Field(i)
+			if !f.CanSet() {
+				f = reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+			}
+			d.walk(f, path+"."+name, depth)
+		}
+	}
+}
+
+// deepcopyTypeKey returns the name of t qualified by its import path.
+func deepcopyTypeKey(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr && t.Name() == "" {
+		return "*" + deepcopyTypeKey(t.Elem())
+	}
+	if t.Name() != "" && t.PkgPath() != "" {
+		return t.PkgPath() + "." + t.Name()
+	}
+	return t.String()
+}
+`
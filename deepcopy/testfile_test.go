@@ -0,0 +1,78 @@
+package deepcopy
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// checkTestFile checks that `src` is a valid go file which contains each of
+// `expected`, ignoring the amount of white space.
+func checkTestFile(t *testing.T, src []byte, expected ...string) {
+	t.Helper()
+	if _, err := parser.ParseFile(token.NewFileSet(), "zz_deepcopy_test.go", src, 0); err != nil {
+		t.Fatalf("%v:\n%s", err, src)
+	}
+	fields := func(s string) string { return strings.Join(strings.Fields(s), " ") }
+	for _, s := range expected {
+		if !strings.Contains(fields(string(src)), fields(s)) {
+			t.Fatalf("expected %q in:\n%s", s, src)
+		}
+	}
+}
+
+func TestGenerateTestFile(t *testing.T) {
+	src, err := GenerateTestFile("deepcopy", structWithTags{}, &structWithStdlibTypes{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestFile(t, src,
+		header,
+		"import (\n\t\"reflect\"\n\t\"strconv\"\n\t\"strings\"\n\t\"testing\"\n\t\"unsafe\"\n)\n",
+		"func TestCopy_structWithStdlibTypes(t *testing.T) {\n\tvar want, o structWithStdlibTypes\n",
+		"deepcopyCheck(t, \"structWithTags\", &want, &o, o.Copy())\n",
+		"\"github.com/cpuguy83/go-generate/deepcopy.structWithTags.A\": \"shallow\",\n",
+		"\"github.com/cpuguy83/go-generate/deepcopy.structWithTags.E\": \"func=cloneInts\",\n",
+		"\"*math/big.Int\": \"opaque\",\n",
+		"\"time.Time\": \"opaque\",\n",
+		"func deepcopyFill(v interface{}, path string) {",
+	)
+
+	src, err = GenerateTestFileWithOptions("deepcopy", []interface{}{simpleStruct{}}, KubernetesStyle())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestFile(t, src, "func TestDeepCopy_simpleStruct(t *testing.T) {", "&want, &o, o.DeepCopy())\n")
+
+	// fields which cannot be copied are left alone
+	if _, err := GenerateTestFile("deepcopy", pathRoot{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+	src, err = GenerateTestFileWithOptions("deepcopy", []interface{}{pathRoot{}}, BestEffort())
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+	checkTestFile(t, src, "\"pathRoot.Items[].Owner.done\": \"zero\",\n")
+}
+
+func TestPackageGenerateTestFile(t *testing.T) {
+	pkg := loadFixtures(t)
+	f, err := pkg.GenerateTestFile([]string{"structWithTagMarkers", "genericSet"}, PointerReceiver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestFile(t, src,
+		"func TestCopy_structWithTagMarkers(t *testing.T) {",
+		"\"github.com/cpuguy83/go-generate/deepcopy.structWithTagMarkers.A\": \"zero\",\n",
+	)
+	// generic types need type arguments to be tested
+	if strings.Contains(string(src), "genericSet") {
+		t.Fatalf("unexpected test for a generic type:\n%s", src)
+	}
+}
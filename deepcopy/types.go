@@ -0,0 +1,187 @@
+package deepcopy
+
+import (
+	"reflect"
+	"strings"
+)
+
+// goType is the subset of reflect.Type that the generator needs in order to
+// walk a type.
+// It lets the same generator work from a reflect.Type, when generating from a
+// live value, and from a types.Type, when generating from package source.
+type goType interface {
+	Kind() reflect.Kind
+	Name() string
+	PkgPath() string
+	// PkgName returns the name of the package the named type belongs to, as
+	// used in its package clause.
+	PkgName() string
+	String() string
+	Elem() goType
+	Key() goType
+	// Len returns the length of an array type.
+	Len() int
+	ChanDir() reflect.ChanDir
+	// PtrTo returns the type of a pointer to the type.
+	PtrTo() goType
+	NumField() int
+	Field(i int) structField
+	MethodByName(name string) (method, bool)
+	Identical(goType) bool
+	// Implementers returns the concrete types known to implement the
+	// interface type, if any.
+	Implementers() []goType
+	// TypeArgs returns the type arguments of an instantiated generic type.
+	TypeArgs() []goType
+}
+
+// structField describes a single field of a struct type.
+type structField struct {
+	Name      string
+	Type      goType
+	Tag       reflect.StructTag
+	Anonymous bool
+
+	// Directive tells the generator how to treat the field.
+	// It is the value of the `deepcopy` struct tag or, when loaded from source,
+	// of a `// +deepcopy:<value>` comment on the field.
+	Directive string
+}
+
+// method describes a method in the method set of a type.
+// The receiver is not included in `In`.
+type method struct {
+	Name string
+	In   []goType
+	Out  []goType
+	// Promoted is set for methods promoted from an embedded field.
+	Promoted bool
+}
+
+// reflectGoType implements goType for a reflect.Type
+type reflectGoType struct {
+	reflect.Type
+}
+
+func fromReflect(t reflect.Type) goType {
+	if t == nil {
+		return nil
+	}
+	return reflectGoType{t}
+}
+
+func (t reflectGoType) Elem() goType {
+	return fromReflect(t.Type.Elem())
+}
+
+func (t reflectGoType) Key() goType {
+	return fromReflect(t.Type.Key())
+}
+
+func (t reflectGoType) PtrTo() goType {
+	return fromReflect(reflect.PtrTo(t.Type))
+}
+
+func (t reflectGoType) Field(i int) structField {
+	f := t.Type.Field(i)
+	return structField{
+		Name:      f.Name,
+		Type:      fromReflect(f.Type),
+		Tag:       f.Tag,
+		Anonymous: f.Anonymous,
+		Directive: f.Tag.Get("deepcopy"),
+	}
+}
+
+func (t reflectGoType) MethodByName(name string) (method, bool) {
+	m, ok := t.Type.MethodByName(name)
+	if !ok {
+		return method{}, false
+	}
+
+	// Methods looked up from a type (rather than from an interface) take the
+	// receiver as the first argument.
+	start := 1
+	if t.Kind() == reflect.Interface {
+		start = 0
+	}
+
+	out := method{Name: m.Name, Promoted: isPromoted(t.Type, name)}
+	for i := start; i < m.Type.NumIn(); i++ {
+		out.In = append(out.In, fromReflect(m.Type.In(i)))
+	}
+	for i := 0; i < m.Type.NumOut(); i++ {
+		out.Out = append(out.Out, fromReflect(m.Type.Out(i)))
+	}
+	return out, true
+}
+
+// isPromoted determines if the method `name` of `t` is promoted from one of
+// its embedded fields.
+// Reflection does not keep track of this, so it is assumed that a method with
+// the same name as one on an embedded field is the promoted method.
+func isPromoted(t reflect.Type, name string) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.Anonymous {
+			continue
+		}
+		if _, ok := f.Type.MethodByName(name); ok {
+			return true
+		}
+		if f.Type.Kind() != reflect.Ptr {
+			if _, ok := reflect.PtrTo(f.Type).MethodByName(name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t reflectGoType) Identical(other goType) bool {
+	o, ok := other.(reflectGoType)
+	return ok && o.Type == t.Type
+}
+
+// Implementers returns nil, there is no way to find the types implementing an
+// interface through reflection.
+func (t reflectGoType) Implementers() []goType {
+	return nil
+}
+
+// PkgName returns the name of the package of the named type `t`, which
+// qualifies its name in `String`.
+func (t reflectGoType) PkgName() string {
+	if t.PkgPath() == "" {
+		return ""
+	}
+	if s := t.String(); strings.HasSuffix(s, "."+t.Name()) {
+		return strings.TrimSuffix(s, "."+t.Name())
+	}
+	return guessPkgName(t.PkgPath())
+}
+
+// TypeArgs returns nil, the type arguments of generic types are not available
+// through reflection.
+func (t reflectGoType) TypeArgs() []goType {
+	return nil
+}
+
+// typeKey returns a string that uniquely identifies the passed in type.
+// It is used to match types against the list of ignored types and the
+// registered copiers, which may come from either a live value or a type name.
+func typeKey(t goType) string {
+	if t.Kind() == reflect.Ptr && t.Name() == "" {
+		return "*" + typeKey(t.Elem())
+	}
+	if name, pkgPath := t.Name(), t.PkgPath(); name != "" && pkgPath != "" {
+		return pkgPath + "." + name
+	}
+	return t.String()
+}
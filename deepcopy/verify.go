@@ -0,0 +1,245 @@
+package deepcopy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+)
+
+// Verify checks that `existing`, the code previously generated for `objs`
+// (such as the contents of the output file), is up to date. The code is
+// generated again, in memory, and a `*StaleError` with a unified diff from
+// `existing` to the new code is returned if they differ.
+// The file is generated in the package of the first object, or in the package
+// named by the package clause of `existing` for unnamed types.
+//
+// It is the same as calling `VerifyWithOptions(existing, objs)`.
+func Verify(existing []byte, objs ...interface{}) error {
+	return VerifyWithOptions(existing, objs)
+}
+
+// VerifyWithOptions is like `Verify`, generating the code with `opts`, which
+// must be the same options it was generated with in the first place.
+func VerifyWithOptions(existing []byte, objs []interface{}, opts ...Option) error {
+	var pkgName string
+	if len(objs) > 0 && reflect.TypeOf(objs[0]) != nil {
+		pkgName = fromReflect(baseType(reflect.TypeOf(objs[0]))).PkgName()
+	}
+	if pkgName == "" {
+		f, err := parser.ParseFile(token.NewFileSet(), "", existing, parser.PackageClauseOnly)
+		if err != nil {
+			return fmt.Errorf("cannot determine the package of the generated code: %v", err)
+		}
+		pkgName = f.Name.Name
+	}
+
+	src, err := GenerateFileWithOptions(pkgName, objs, opts...)
+	return compareGenerated(existing, src, err)
+}
+
+// Verify checks that `existing`, the code previously generated for the types
+// named in `typeNames` (or the marked types if there are none), is up to date.
+// See `Verify` and `GenerateFile` for details.
+func (p *Package) Verify(existing []byte, typeNames []string, opts ...Option) error {
+	f, err := p.GenerateFile(typeNames, opts...)
+	if f == nil {
+		return err
+	}
+	src, ferr := f.Bytes()
+	if ferr != nil {
+		return ferr
+	}
+	return compareGenerated(existing, src, err)
+}
+
+// Verify checks that `existing` is the same as the source of the file, and
+// returns a `*StaleError` with a unified diff from `existing` to it if it is
+// not.
+func (f *File) Verify(existing []byte) error {
+	src, err := f.Bytes()
+	if err != nil {
+		return err
+	}
+	return compareGenerated(existing, src, nil)
+}
+
+// compareGenerated compares the `existing` code with the code just generated,
+// `src`, along with the error returned when generating it.
+// The errors for fields which were skipped with the `BestEffort` option are
+// not reported since the generated code has a comment for each of them.
+func compareGenerated(existing, src []byte, err error) error {
+	if src == nil {
+		return err
+	}
+	var errs Errors
+	var typeErr *TypeError
+	if err != nil && !errors.As(err, &errs) && !errors.As(err, &typeErr) {
+		return err
+	}
+	if bytes.Equal(existing, src) {
+		return nil
+	}
+	return &StaleError{Diff: unifiedDiff("existing", "generated", existing, src)}
+}
+
+// diffContext is the number of unchanged lines shown around each change in a
+// diff.
+const diffContext = 3
+
+// diffLine is a line of a diff, `op` is one of ' ', '-' or '+'.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff returns a unified diff from `a` to `b`, labelled `aName` and
+// `bName`, or an empty string if they are the same.
+func unifiedDiff(aName, bName string, a, b []byte) string {
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	// aPos and bPos are the number of lines of each side before each line of
+	// the diff
+	aPos := make([]int, len(lines)+1)
+	bPos := make([]int, len(lines)+1)
+	for i, l := range lines {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if l.op != '+' {
+			aPos[i+1]++
+		}
+		if l.op != '-' {
+			bPos[i+1]++
+		}
+	}
+
+	out := &strings.Builder{}
+	for i := 0; i < len(lines); {
+		for i < len(lines) && lines[i].op == ' ' {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(out, "--- %s\n+++ %s\n", aName, bName)
+		}
+
+		// the hunk takes in the following changes as long as their context
+		// overlaps
+		start, end := i-diffContext, i
+		if start < 0 {
+			start = 0
+		}
+		for j := i; j < len(lines) && j-end <= 2*diffContext; j++ {
+			if lines[j].op != ' ' {
+				end = j + 1
+			}
+		}
+		stop := end + diffContext
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aPos[start], aPos[stop]), hunkRange(bPos[start], bPos[stop]))
+		for _, l := range lines[start:stop] {
+			out.WriteByte(l.op)
+			out.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = stop
+	}
+	return out.String()
+}
+
+// hunkRange formats the lines from `start` to `end` of one side of a hunk.
+func hunkRange(start, end int) string {
+	if start == end {
+		// an empty range refers to the line before it
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end-start)
+}
+
+// splitLines splits `b` into lines, keeping the line endings.
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the shortest list of lines to remove from `a` and add
+// from `b` to turn `a` into `b`, interleaved with the lines they have in
+// common, using Myers' algorithm.
+func diffLines(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	// v holds the furthest x reached on each diagonal k = x - y
+	v := make([]int, 2*max+3)
+	// trace holds the diagonals -d..d of v after each step d
+	var trace [][]int
+search:
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+				break search
+			}
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+	}
+
+	var lines []diffLine
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]) {
+			prevK = k + 1
+		}
+		prevX := prev[prevK+d-1]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			lines = append(lines, diffLine{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			lines = append(lines, diffLine{'+', b[y-1]})
+			y--
+		} else {
+			lines = append(lines, diffLine{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		lines = append(lines, diffLine{' ', a[x-1]})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
@@ -0,0 +1,110 @@
+package deepcopy
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+	b := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\no"
+	expected := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -12,3 +12,4 @@
+ l
+ m
+ n
++o
+\ No newline at end of file
+`
+	if actual := unifiedDiff("old", "new", []byte(a), []byte(b)); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	if actual := unifiedDiff("old", "new", []byte(a), []byte(a)); actual != "" {
+		t.Fatalf("expected no diff, got:\n%s", actual)
+	}
+	expected = "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+x\n+y\n"
+	if actual := unifiedDiff("old", "new", nil, []byte("x\ny\n")); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		out := make([]string, r.Intn(20))
+		for i := range out {
+			out[i] = string(rune('a' + r.Intn(4)))
+		}
+		return out
+	}
+	for i := 0; i < 500; i++ {
+		a, b := lines(), lines()
+		var gotA, gotB []string
+		for _, l := range diffLines(a, b) {
+			if l.op != '+' {
+				gotA = append(gotA, l.text)
+			}
+			if l.op != '-' {
+				gotB = append(gotB, l.text)
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("diff of %q and %q does not give them back: %q and %q", a, b, gotA, gotB)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	objs := []interface{}{simpleStruct{}, structWithImports{}}
+	src, err := GenerateFile("deepcopy", objs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(src, objs...); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := bytes.Replace(src, []byte("oCopy.A.B[i0] = v0"), []byte("oCopy.A.B[i0] = v0 + \"\""), 1)
+	err = Verify(stale, objs...)
+	var staleErr *StaleError
+	if !errors.As(err, &staleErr) || !errors.Is(err, ErrStale) {
+		t.Fatalf("expected '%v', got: %v", ErrStale, err)
+	}
+	if !strings.Contains(staleErr.Diff, "-\t\t\t\toCopy.A.B[i0] = v0 + \"\"\n+\t\t\t\toCopy.A.B[i0] = v0\n") {
+		t.Fatalf("unexpected diff:\n%s", staleErr.Diff)
+	}
+
+	// the options must match
+	if err := VerifyWithOptions(src, objs, PointerReceiver()); !errors.Is(err, ErrStale) {
+		t.Fatalf("expected '%v', got: %v", ErrStale, err)
+	}
+	if err := Verify(src, worker{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected '%v', got: %v", ErrUnsupportedType, err)
+	}
+	if err := VerifyWithOptions(nil, []interface{}{worker{}}, BestEffort()); !errors.Is(err, ErrStale) {
+		t.Fatalf("expected '%v', got: %v", ErrStale, err)
+	}
+}
+
+func TestPackageVerify(t *testing.T) {
+	pkg := loadFixtures(t)
+	names := []string{"simpleStruct", "structWithImports"}
+	if err := pkg.Verify(fileX, names, BuildTags("linux && !race")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pkg.Verify(fileX, names); !errors.Is(err, ErrStale) || !strings.Contains(err.Error(), "-//go:build linux && !race\n") {
+		t.Fatalf("expected '%v', got: %v", ErrStale, err)
+	}
+}